@@ -1,30 +1,36 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"yuv/internal/utils"
+	"yuv/pkg/key"
 	pkg "yuv/pkg/pkgmgr"
 	"yuv/pkg/repo"
 	"yuv/pkg/system"
 )
 
 var (
-	detector  = system.NewDetector()
-	repoMgr   = repo.NewManager()
+	detector   = system.NewDetector()
+	repoMgr    = repo.NewManager()
 	packageMgr = pkg.NewManager()
+	keyMgr     = key.NewManager()
 )
 
 func main() {
-	// 检测系统是否支持
-	supported, err := detector.IsSupported()
-	if err != nil {
-		log.Fatalf("检测系统失败: %v", err)
-	}
-	if !supported {
-		log.Fatalf("当前系统不支持")
-	}
+	// 记录当前命令，写入备份清单
+	repoMgr.Command = strings.Join(os.Args, " ")
+
+	// 备用根目录，默认取 YUV_ROOT 环境变量
+	var root string
 
 	// 创建根命令
 	var rootCmd = &cobra.Command{
@@ -32,10 +38,42 @@ func main() {
 		Short: "轻量级高性能 YUM/DNF 增强工具",
 		Long: `yuv (yum+uv) 是一款轻量级、高性能、零负担的 Linux RPM 包管理器增强工具。
 它提供一键式 yum 源管理和快速包安装功能，兼容 yum/dnf 命令。`,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			// 所有文件路径都位于备用根目录下，包管理命令使用 --installroot
+			if root != "" {
+				abs, err := filepath.Abs(root)
+				if err != nil {
+					log.Fatalf("解析根目录失败: %v", err)
+				}
+				if info, err := os.Stat(abs); err != nil || !info.IsDir() {
+					log.Fatalf("根目录 %s 不存在", abs)
+				}
+				detector.Root = abs
+				repoMgr.SetRoot(abs)
+				repo.SetCatalogRoot(abs)
+				packageMgr.InstallRoot = abs
+				keyMgr.Root = abs
+			}
+
+			// yuv 自身发起的 HTTP 请求使用全局代理
+			if err := repoMgr.LoadProxy(); err != nil {
+				log.Fatalf("读取代理配置失败: %v", err)
+			}
+
+			// 检测系统是否支持
+			supported, err := detector.IsSupported()
+			if err != nil {
+				log.Fatalf("检测系统失败: %v", err)
+			}
+			if !supported {
+				log.Fatalf("当前系统不支持")
+			}
+		},
 		Run: func(cmd *cobra.Command, args []string) {
 			cmd.Help()
 		},
 	}
+	rootCmd.PersistentFlags().StringVar(&root, "root", os.Getenv("YUV_ROOT"), "备用根目录（如挂载的镜像或 chroot），也可通过 YUV_ROOT 环境变量设置")
 
 	// 源管理命令组
 	repoCmd := &cobra.Command{
@@ -46,12 +84,14 @@ func main() {
 		},
 	}
 
+	repoCmd.PersistentFlags().BoolVar(&repoMgr.DryRun, "dry-run", false, "只显示将要进行的文件变更和执行的命令，不实际修改")
+
 	// use 命令
-	repoCmd.AddCommand(&cobra.Command{
-		Use:   "use [repo]",
-		Short: "切换到指定的公共仓库",
-		Example: "yuv repo use aliyun",
-		Args:  cobra.ExactArgs(1),
+	useCmd := &cobra.Command{
+		Use:     "use [repo]",
+		Short:   "切换到指定的公共仓库，official 恢复发行版自带的官方源",
+		Example: "yuv repo use aliyun\n  yuv repo use auto\n  yuv repo use official",
+		Args:    cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			releasever, err := detector.GetReleasever()
 			if err != nil {
@@ -63,20 +103,102 @@ func main() {
 			}
 
 			repoName := args[0]
+			// auto 表示测速后自动选择最快的镜像源
+			if repoName == repo.AutoMirror {
+				fastest, results, err := repoMgr.FastestMirror(releasever, basearch)
+				printBenchResults(results)
+				if err != nil {
+					log.Fatalf("选择最快镜像源失败: %v", err)
+				}
+				fmt.Printf("最快的镜像源: %s\n", fastest)
+				repoName = fastest
+			}
+			repoMgr.SkipVerify, _ = cmd.Flags().GetBool("no-verify")
 			if err := repoMgr.Use(repoName, releasever, basearch); err != nil {
 				log.Fatalf("使用仓库失败: %v", err)
 			}
+			if repoMgr.DryRun {
+				printPlans(repoMgr.Plans)
+				return
+			}
+			if repoName == repo.OfficialMirror {
+				fmt.Println("成功恢复发行版官方源")
+				// 已停止维护的系统官方镜像已下线，提示迁移到 vault
+				if distro, err := detector.Detect(); err == nil && distro.IsEOL(time.Now()) {
+					fmt.Printf("提示: %s %s 已停止维护，官方镜像已下线，可运行 yuv repo fix-eol 迁移到 vault\n", distro.Name, distro.Version)
+				}
+				return
+			}
 			fmt.Printf("成功切换到 %s 仓库\n", repoName)
 		},
-	})
+	}
+	useCmd.Flags().Bool("no-verify", false, "写入前不校验新仓库的元数据")
+	repoCmd.AddCommand(useCmd)
 
 	// add 命令
-	repoCmd.AddCommand(&cobra.Command{
-		Use:   "add [repo]",
-		Short: "添加指定的仓库",
-		Example: "yuv repo add mysql8",
-		Args:  cobra.ExactArgs(1),
+	addCmd := &cobra.Command{
+		Use:   "add [repo|url|copr:owner/project]",
+		Short: "添加预置仓库、远程 .repo 文件、Copr 项目或自定义仓库",
+		Example: `yuv repo add mysql8
+  yuv repo add nodejs@20
+  yuv repo add k8s@1.30
+  yuv repo add copr:@caddy/caddy
+  yuv repo add https://example.com/foo.repo
+  yuv repo add --id myrepo --baseurl https://mirror.example.com/el9/ --gpgkey https://mirror.example.com/RPM-GPG-KEY --priority 10
+  yuv repo add --id internal --baseurl http://repo.corp.local/el9/ --proxy _none_`,
+		Args: cobra.MaximumNArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
+			repoMgr.SkipVerify, _ = cmd.Flags().GetBool("no-verify")
+
+			// 指定 --id 时添加自定义仓库
+			if id, _ := cmd.Flags().GetString("id"); id != "" {
+				if len(args) > 0 {
+					log.Fatalf("使用 --id 添加自定义仓库时不能再指定仓库名")
+				}
+				custom := &repo.CustomRepo{ID: id}
+				custom.Name, _ = cmd.Flags().GetString("name")
+				custom.BaseURLs, _ = cmd.Flags().GetStringSlice("baseurl")
+				custom.GPGKeys, _ = cmd.Flags().GetStringSlice("gpgkey")
+				custom.Priority, _ = cmd.Flags().GetInt("priority")
+				custom.Excludes, _ = cmd.Flags().GetStringSlice("exclude")
+				custom.Disabled, _ = cmd.Flags().GetBool("disabled")
+				custom.Proxy = proxyFromFlags(cmd)
+				if len(custom.GPGKeys) == 0 {
+					fmt.Println("警告: 未指定 --gpgkey，将关闭该仓库的 GPG 校验")
+				}
+				path, err := repoMgr.AddCustom(custom)
+				if err != nil {
+					log.Fatalf("添加自定义仓库失败: %v", err)
+				}
+				if repoMgr.DryRun {
+					printPlans(repoMgr.Plans)
+					return
+				}
+				fmt.Printf("成功添加 %s 仓库 (%s)\n", id, path)
+				return
+			}
+
+			if len(args) == 0 {
+				log.Fatalf("请指定仓库名、.repo 文件地址、Copr 项目或使用 --id 添加自定义仓库")
+			}
+			repoName := args[0]
+
+			// 远程 .repo 文件
+			if strings.HasPrefix(repoName, "http://") || strings.HasPrefix(repoName, "https://") {
+				ids, err := repoMgr.AddRemote(repoName)
+				if err != nil {
+					log.Fatalf("添加仓库失败: %v", err)
+				}
+				if repoMgr.DryRun {
+					printPlans(repoMgr.Plans)
+					return
+				}
+				for _, id := range ids {
+					fmt.Printf("成功添加 %s 仓库\n", id)
+				}
+				return
+			}
+
 			releasever, err := detector.GetReleasever()
 			if err != nil {
 				log.Fatalf("获取 releasever 失败: %v", err)
@@ -86,105 +208,506 @@ func main() {
 				log.Fatalf("获取 basearch 失败: %v", err)
 			}
 
-			repoName := args[0]
 			if err := repoMgr.Add(repoName, releasever, basearch); err != nil {
 				log.Fatalf("添加仓库失败: %v", err)
 			}
+			if repoMgr.DryRun {
+				printPlans(repoMgr.Plans)
+				return
+			}
 			fmt.Printf("成功添加 %s 仓库\n", repoName)
 		},
-	})
+	}
+	addCmd.Flags().String("id", "", "自定义仓库 ID")
+	addCmd.Flags().String("name", "", "自定义仓库描述")
+	addCmd.Flags().StringSlice("baseurl", nil, "自定义仓库地址，可多次指定")
+	addCmd.Flags().StringSlice("gpgkey", nil, "GPG 密钥地址，可多次指定")
+	addCmd.Flags().Int("priority", 0, "仓库优先级（1-99）")
+	addCmd.Flags().StringSlice("exclude", nil, "排除的软件包，可多次指定")
+	addCmd.Flags().Bool("disabled", false, "添加后保持禁用")
+	addCmd.Flags().String("proxy", "", "自定义仓库使用的代理，_none_ 表示不使用全局代理")
+	addCmd.Flags().String("proxy-user", "", "代理认证用户名")
+	addCmd.Flags().Bool("proxy-password-stdin", false, "从标准输入读取代理认证密码，未指定时使用 YUV_PROXY_PASSWORD 环境变量")
+	addCmd.Flags().Bool("no-verify", false, "写入前不校验新仓库的元数据")
+	repoCmd.AddCommand(addCmd)
 
 	// remove 命令
 	repoCmd.AddCommand(&cobra.Command{
-		Use:   "remove [repo]",
-		Short: "移除指定的仓库",
-		Example: "yuv repo remove mysql8",
-		Args:  cobra.ExactArgs(1),
+		Use:     "remove [repo...]",
+		Short:   "移除指定的仓库（支持仓库 ID、通配符或 copr:owner/project）",
+		Example: "yuv repo remove mysql\n  yuv repo remove 'aliyun-*'\n  yuv repo remove copr:@caddy/caddy",
+		Args:    cobra.MinimumNArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			repoName := args[0]
-			if err := repoMgr.Remove(repoName); err != nil {
+			ids, err := repoMgr.Remove(args...)
+			if err != nil {
 				log.Fatalf("移除仓库失败: %v", err)
 			}
-			fmt.Printf("成功移除 %s 仓库\n", repoName)
+			if repoMgr.DryRun {
+				printPlans(repoMgr.Plans)
+				return
+			}
+			for _, id := range ids {
+				fmt.Printf("成功移除 %s 仓库\n", id)
+			}
 		},
 	})
 
 	// list 命令
-	repoCmd.AddCommand(&cobra.Command{
-		Use:   "list",
-		Short: "列出所有仓库",
+	listCmd := &cobra.Command{
+		Use:     "list",
+		Short:   "列出所有仓库",
+		Example: "yuv repo list --enabled\n  yuv repo list --copr\n  yuv repo list --output json",
 		Run: func(cmd *cobra.Command, args []string) {
+			onlyEnabled, _ := cmd.Flags().GetBool("enabled")
+			onlyDisabled, _ := cmd.Flags().GetBool("disabled")
+			onlyCopr, _ := cmd.Flags().GetBool("copr")
+			output, _ := cmd.Flags().GetString("output")
+			if onlyEnabled && onlyDisabled {
+				log.Fatalf("--enabled 与 --disabled 不能同时使用")
+			}
+
 			repos, err := repoMgr.List()
 			if err != nil {
 				log.Fatalf("列出仓库失败: %v", err)
 			}
-			fmt.Println("当前仓库:")
+
+			// 按启用状态和 Copr 标记过滤
+			filtered := []*repo.RepoInfo{}
 			for _, r := range repos {
-				fmt.Printf("  - %s\n", r)
+				if (onlyEnabled && !r.Enabled) || (onlyDisabled && r.Enabled) || (onlyCopr && r.Copr == "") {
+					continue
+				}
+				filtered = append(filtered, r)
+			}
+
+			switch output {
+			case "json":
+				if err := printJSON(filtered); err != nil {
+					log.Fatalf("输出 JSON 失败: %v", err)
+				}
+			case "table":
+				printRepoTable(filtered)
+			default:
+				log.Fatalf("不支持的输出格式: %s", output)
+			}
+		},
+	}
+	listCmd.Flags().Bool("enabled", false, "只显示已启用的仓库")
+	listCmd.Flags().Bool("disabled", false, "只显示已禁用的仓库")
+	listCmd.Flags().Bool("copr", false, "只显示通过 yuv 添加的 Copr 仓库")
+	listCmd.Flags().StringP("output", "o", "table", "输出格式: table 或 json")
+	repoCmd.AddCommand(listCmd)
+
+	// versions 命令
+	versionsCmd := &cobra.Command{
+		Use:     "versions [repo...]",
+		Short:   "列出预置仓库可用的版本，使用 yuv repo add <仓库>@<版本> 添加或切换版本",
+		Example: "yuv repo versions\n  yuv repo versions nodejs k8s",
+		Run: func(cmd *cobra.Command, args []string) {
+			output, _ := cmd.Flags().GetString("output")
+			releasever, err := detector.GetReleasever()
+			if err != nil {
+				log.Fatalf("获取 releasever 失败: %v", err)
+			}
+			basearch, err := detector.GetBasearch()
+			if err != nil {
+				log.Fatalf("获取 basearch 失败: %v", err)
+			}
+			versions, err := repoMgr.Versions(args, releasever, basearch)
+			if err != nil {
+				log.Fatalf("列出版本失败: %v", err)
+			}
+
+			switch output {
+			case "json":
+				if err := printJSON(versions); err != nil {
+					log.Fatalf("输出 JSON 失败: %v", err)
+				}
+			case "table":
+				printVersions(versions)
+			default:
+				log.Fatalf("不支持的输出格式: %s", output)
+			}
+		},
+	}
+	versionsCmd.Flags().StringP("output", "o", "table", "输出格式: table 或 json")
+	repoCmd.AddCommand(versionsCmd)
+
+	// check 命令
+	checkCmd := &cobra.Command{
+		Use:     "check [repo...]",
+		Short:   "检查仓库是否可用（元数据、校验和与 GPG 密钥）",
+		Example: "yuv repo check\n  yuv repo check 'aliyun-*'",
+		Run: func(cmd *cobra.Command, args []string) {
+			output, _ := cmd.Flags().GetString("output")
+			results, err := repoMgr.Check(args...)
+			if err != nil {
+				log.Fatalf("检查仓库失败: %v", err)
+			}
+
+			switch output {
+			case "json":
+				if err := printJSON(results); err != nil {
+					log.Fatalf("输出 JSON 失败: %v", err)
+				}
+			case "table":
+				printCheckResults(results)
+			default:
+				log.Fatalf("不支持的输出格式: %s", output)
 			}
+
+			// 有仓库不可用时返回非零退出码，便于在 CI 中使用
+			for _, r := range results {
+				if !r.OK {
+					os.Exit(1)
+				}
+			}
+		},
+	}
+	checkCmd.Flags().StringP("output", "o", "table", "输出格式: table 或 json")
+	repoCmd.AddCommand(checkCmd)
+
+	// bench 命令
+	repoCmd.AddCommand(&cobra.Command{
+		Use:   "bench",
+		Short: "测试所有支持当前系统的镜像源速度并排序",
+		Run: func(cmd *cobra.Command, args []string) {
+			releasever, err := detector.GetReleasever()
+			if err != nil {
+				log.Fatalf("获取 releasever 失败: %v", err)
+			}
+			basearch, err := detector.GetBasearch()
+			if err != nil {
+				log.Fatalf("获取 basearch 失败: %v", err)
+			}
+
+			results, err := repoMgr.Bench(releasever, basearch)
+			if err != nil {
+				log.Fatalf("镜像源测速失败: %v", err)
+			}
+			printBenchResults(results)
 		},
 	})
 
+	// doctor 命令
+	doctorCmd := &cobra.Command{
+		Use:     "doctor",
+		Short:   "结合当前系统诊断所有仓库配置，可自动修复安全的问题",
+		Example: "yuv repo doctor\n  yuv repo doctor --fix --dry-run",
+		Args:    cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			repoMgr.SkipVerify, _ = cmd.Flags().GetBool("no-verify")
+			fix, _ := cmd.Flags().GetBool("fix")
+			output, _ := cmd.Flags().GetString("output")
+			findings, err := repoMgr.Doctor(fix)
+			if err != nil {
+				log.Fatalf("诊断仓库失败: %v", err)
+			}
+
+			switch output {
+			case "json":
+				if err := printJSON(findings); err != nil {
+					log.Fatalf("输出 JSON 失败: %v", err)
+				}
+			case "table":
+				printFindings(findings)
+				if repoMgr.DryRun && fix {
+					printPlans(repoMgr.Plans)
+				}
+			default:
+				log.Fatalf("不支持的输出格式: %s", output)
+			}
+
+			// 仍有未修复的错误时返回非零退出码
+			for _, f := range findings {
+				if f.Severity == repo.SeverityError && !f.Fixed {
+					os.Exit(1)
+				}
+			}
+		},
+	}
+	doctorCmd.Flags().Bool("fix", false, "自动修复可以安全修复的问题，修复前创建快照")
+	doctorCmd.Flags().Bool("no-verify", false, "不检查仓库能否访问")
+	doctorCmd.Flags().StringP("output", "o", "table", "输出格式: table 或 json")
+	repoCmd.AddCommand(doctorCmd)
+
+	// fix-eol 命令
+	fixEOLCmd := &cobra.Command{
+		Use:     "fix-eol",
+		Short:   "将已停止维护系统中指向已下线镜像的仓库迁移到 vault",
+		Example: "yuv repo fix-eol\n  yuv repo fix-eol --mirror aliyun --dry-run",
+		Args:    cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			repoMgr.SkipVerify, _ = cmd.Flags().GetBool("no-verify")
+			mirror, _ := cmd.Flags().GetString("mirror")
+			report, err := repoMgr.FixEOL(mirror)
+			if err != nil {
+				log.Fatalf("迁移 EOL 仓库失败: %v", err)
+			}
+			if report.EOL.IsZero() {
+				fmt.Printf("%s %s 仍在维护，无需迁移\n", report.Distro, report.Version)
+				return
+			}
+			fmt.Printf("%s %s 已于 %s 停止维护，使用 %s 的 vault\n", report.Distro, report.Version, report.EOL.Format("2006-01-02"), report.Vault)
+			if len(report.Fixes) == 0 {
+				fmt.Println("没有指向已下线镜像的仓库")
+				return
+			}
+			printEOLFixes(report.Fixes)
+			if repoMgr.DryRun {
+				printPlans(repoMgr.Plans)
+				return
+			}
+			fmt.Printf("成功迁移 %d 个仓库\n", len(report.Fixes))
+		},
+	}
+	fixEOLCmd.Flags().String("mirror", "", "使用指定公共镜像源的 vault 目录，默认使用官方 vault")
+	fixEOLCmd.Flags().Bool("no-verify", false, "写入前不校验新仓库的元数据")
+	repoCmd.AddCommand(fixEOLCmd)
+
 	// backup 命令
-	repoCmd.AddCommand(&cobra.Command{
+	backupCmd := &cobra.Command{
 		Use:   "backup",
-		Short: "备份所有仓库",
+		Short: "为所有仓库创建备份快照",
 		Run: func(cmd *cobra.Command, args []string) {
-			if err := repoMgr.Backup(); err != nil {
+			if keep, _ := cmd.Flags().GetInt("keep"); cmd.Flags().Changed("keep") {
+				repoMgr.KeepBackups = keep
+			}
+			if repoMgr.DryRun {
+				fmt.Println("预览模式，未创建快照")
+				return
+			}
+			snapshot, err := repoMgr.Backup()
+			if err != nil {
 				log.Fatalf("备份仓库失败: %v", err)
 			}
-			fmt.Println("成功备份所有仓库")
+			fmt.Printf("成功备份所有仓库，快照 ID: %s (%d 个文件)\n", snapshot.ID, len(snapshot.Files))
+		},
+	}
+	backupCmd.Flags().Int("keep", repo.DefaultKeepBackups, "最多保留的快照数量，0 表示不清理")
+
+	backupCmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "列出所有备份快照",
+		Run: func(cmd *cobra.Command, args []string) {
+			snapshots, err := repoMgr.ListBackups()
+			if err != nil {
+				log.Fatalf("列出备份失败: %v", err)
+			}
+			printSnapshots(snapshots)
 		},
 	})
 
+	backupCmd.AddCommand(&cobra.Command{
+		Use:     "diff [id]",
+		Short:   "比较备份快照与当前仓库配置",
+		Example: "yuv repo backup diff 20240101-120000",
+		Args:    cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			id := ""
+			if len(args) > 0 {
+				id = args[0]
+			}
+			diff, err := repoMgr.DiffBackup(id)
+			if err != nil {
+				log.Fatalf("比较备份失败: %v", err)
+			}
+			if diff == "" {
+				fmt.Println("当前仓库配置与备份一致")
+				return
+			}
+			fmt.Print(diff)
+		},
+	})
+	repoCmd.AddCommand(backupCmd)
+
 	// restore 命令
-	repoCmd.AddCommand(&cobra.Command{
-		Use:   "restore",
-		Short: "从备份恢复仓库",
+	restoreCmd := &cobra.Command{
+		Use:     "restore [id]",
+		Short:   "将仓库配置恢复到备份快照时的状态，默认使用最新的快照",
+		Example: "yuv repo restore\n  yuv repo restore 20240101-120000 --dry-run",
+		Args:    cobra.MaximumNArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			if err := repoMgr.Restore(); err != nil {
+			id := ""
+			if len(args) > 0 {
+				id = args[0]
+			}
+			changes, err := repoMgr.Restore(id)
+			if err != nil {
 				log.Fatalf("恢复仓库失败: %v", err)
 			}
+			if len(changes) == 0 {
+				fmt.Println("当前仓库配置与备份一致，无需恢复")
+				return
+			}
+			if repoMgr.DryRun {
+				printPlans(repoMgr.Plans)
+				return
+			}
+			printFileChanges(changes)
 			fmt.Println("成功从备份恢复仓库")
 		},
-	})
+	}
+	repoCmd.AddCommand(restoreCmd)
 
 	// enable 命令
 	repoCmd.AddCommand(&cobra.Command{
-		Use:   "enable [repo]",
-		Short: "启用指定的仓库",
-		Example: "yuv repo enable mysql8",
-		Args:  cobra.ExactArgs(1),
+		Use:     "enable [repo...]",
+		Short:   "启用指定的仓库（支持仓库 ID 或通配符）",
+		Example: "yuv repo enable mysql\n  yuv repo enable 'aliyun-*'",
+		Args:    cobra.MinimumNArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			repoName := args[0]
-			if err := repoMgr.Enable(repoName); err != nil {
+			ids, err := repoMgr.Enable(args...)
+			if err != nil {
 				log.Fatalf("启用仓库失败: %v", err)
 			}
-			fmt.Printf("成功启用 %s 仓库\n", repoName)
+			if repoMgr.DryRun {
+				printPlans(repoMgr.Plans)
+				return
+			}
+			for _, id := range ids {
+				fmt.Printf("成功启用 %s 仓库\n", id)
+			}
 		},
 	})
 
 	// disable 命令
 	repoCmd.AddCommand(&cobra.Command{
-		Use:   "disable [repo]",
-		Short: "禁用指定的仓库",
-		Example: "yuv repo disable mysql8",
-		Args:  cobra.ExactArgs(1),
+		Use:     "disable [repo...]",
+		Short:   "禁用指定的仓库（支持仓库 ID 或通配符）",
+		Example: "yuv repo disable mysql\n  yuv repo disable 'aliyun-*'",
+		Args:    cobra.MinimumNArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			repoName := args[0]
-			if err := repoMgr.Disable(repoName); err != nil {
+			ids, err := repoMgr.Disable(args...)
+			if err != nil {
 				log.Fatalf("禁用仓库失败: %v", err)
 			}
-			fmt.Printf("成功禁用 %s 仓库\n", repoName)
+			if repoMgr.DryRun {
+				printPlans(repoMgr.Plans)
+				return
+			}
+			for _, id := range ids {
+				fmt.Printf("成功禁用 %s 仓库\n", id)
+			}
+		},
+	})
+
+	// proxy 命令组
+	proxyCmd := &cobra.Command{
+		Use:   "proxy",
+		Short: "管理代理配置",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	proxyCmd.AddCommand(&cobra.Command{
+		Use:   "show",
+		Short: "显示全局代理和单独设置了代理的仓库",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			repos, err := repoMgr.List()
+			if err != nil {
+				log.Fatalf("列出仓库失败: %v", err)
+			}
+			printProxies(repoMgr.Proxy, repos)
 		},
 	})
 
+	proxySetCmd := &cobra.Command{
+		Use:   "set [url]",
+		Short: "设置全局代理，或用 --repo 为指定仓库单独设置代理",
+		Long: `设置全局代理时写入 /etc/yuv/proxy.yaml 和 dnf/yum 主配置文件的 [main] 节，
+yuv 自身发起的 HTTP 请求也使用该代理；--no-proxy 中的主机不使用代理，生成的仓库地址都在其中时写入 proxy=_none_。
+使用 --repo 时只修改匹配仓库的 proxy 选项，url 为 _none_ 表示该仓库不使用全局代理。`,
+		Example: `yuv repo proxy set http://proxy.example.com:3128 --no-proxy localhost,.corp.local,10.0.0.0/8
+  echo "$PROXY_PASSWORD" | yuv repo proxy set http://proxy.example.com:3128 --user build --password-stdin
+  yuv repo proxy set _none_ --repo 'internal-*'`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			proxy := &utils.Proxy{URL: args[0]}
+			if proxy.URL == repo.ProxyNone {
+				proxy.URL = ""
+			}
+			proxy.Username, _ = cmd.Flags().GetString("user")
+			proxy.Password = proxyPassword(cmd, "password-stdin", proxy.Username)
+			proxy.NoProxy, _ = cmd.Flags().GetStringSlice("no-proxy")
+
+			if patterns, _ := cmd.Flags().GetStringSlice("repo"); len(patterns) > 0 {
+				if len(proxy.NoProxy) > 0 {
+					log.Fatalf("--no-proxy 只能用于全局代理")
+				}
+				ids, err := repoMgr.SetRepoProxy(proxy, patterns...)
+				if err != nil {
+					log.Fatalf("设置仓库代理失败: %v", err)
+				}
+				if repoMgr.DryRun {
+					printPlans(repoMgr.Plans)
+					return
+				}
+				for _, id := range ids {
+					fmt.Printf("成功设置 %s 仓库的代理\n", id)
+				}
+				return
+			}
+
+			if err := repoMgr.SetProxy(proxy); err != nil {
+				log.Fatalf("设置全局代理失败: %v", err)
+			}
+			if repoMgr.DryRun {
+				printPlans(repoMgr.Plans)
+				return
+			}
+			fmt.Printf("成功设置全局代理 %s\n", proxy.Redacted())
+		},
+	}
+	proxySetCmd.Flags().String("user", "", "代理认证用户名")
+	proxySetCmd.Flags().Bool("password-stdin", false, "从标准输入读取代理认证密码，未指定时使用 YUV_PROXY_PASSWORD 环境变量")
+	proxySetCmd.Flags().StringSlice("no-proxy", nil, "不使用代理的主机、域名后缀或网段，可多次指定")
+	proxySetCmd.Flags().StringSlice("repo", nil, "只为匹配的仓库设置代理（支持仓库 ID 或通配符），可多次指定")
+	proxyCmd.AddCommand(proxySetCmd)
+
+	proxyUnsetCmd := &cobra.Command{
+		Use:     "unset",
+		Short:   "删除全局代理，或用 --repo 删除指定仓库单独设置的代理",
+		Example: "yuv repo proxy unset\n  yuv repo proxy unset --repo 'internal-*'",
+		Args:    cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if patterns, _ := cmd.Flags().GetStringSlice("repo"); len(patterns) > 0 {
+				ids, err := repoMgr.SetRepoProxy(nil, patterns...)
+				if err != nil {
+					log.Fatalf("删除仓库代理失败: %v", err)
+				}
+				if repoMgr.DryRun {
+					printPlans(repoMgr.Plans)
+					return
+				}
+				for _, id := range ids {
+					fmt.Printf("成功删除 %s 仓库的代理\n", id)
+				}
+				return
+			}
+
+			if err := repoMgr.UnsetProxy(); err != nil {
+				log.Fatalf("删除全局代理失败: %v", err)
+			}
+			if repoMgr.DryRun {
+				printPlans(repoMgr.Plans)
+				return
+			}
+			fmt.Println("成功删除全局代理")
+		},
+	}
+	proxyUnsetCmd.Flags().StringSlice("repo", nil, "只删除匹配仓库的代理（支持仓库 ID 或通配符），可多次指定")
+	proxyCmd.AddCommand(proxyUnsetCmd)
+	repoCmd.AddCommand(proxyCmd)
+
 	// 包管理命令
 	installCmd := &cobra.Command{
-		Use:   "install [packages...]",
-		Short: "安装软件包",
+		Use:     "install [packages...]",
+		Short:   "安装软件包",
 		Example: "yuv install nginx",
-		Args:  cobra.MinimumNArgs(1),
+		Args:    cobra.MinimumNArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			if err := packageMgr.Install(args...); err != nil {
 				log.Fatalf("安装软件包失败: %v", err)
@@ -343,6 +866,258 @@ func main() {
 	// 添加 repo 命令组到根命令
 	rootCmd.AddCommand(repoCmd)
 
+	// 密钥管理命令组
+	keyCmd := &cobra.Command{
+		Use:   "key",
+		Short: "管理仓库 GPG 密钥",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	// key list 命令
+	keyListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "列出已导入 rpm 的 GPG 密钥",
+		Run: func(cmd *cobra.Command, args []string) {
+			output, _ := cmd.Flags().GetString("output")
+			keys, err := keyMgr.List()
+			if err != nil {
+				log.Fatalf("列出密钥失败: %v", err)
+			}
+			switch output {
+			case "json":
+				if err := printJSON(keys); err != nil {
+					log.Fatalf("输出 JSON 失败: %v", err)
+				}
+			case "table":
+				printInstalledKeys(keys)
+			default:
+				log.Fatalf("不支持的输出格式: %s", output)
+			}
+		},
+	}
+	keyListCmd.Flags().StringP("output", "o", "table", "输出格式: table 或 json")
+	keyCmd.AddCommand(keyListCmd)
+
+	// key import 命令
+	keyImportCmd := &cobra.Command{
+		Use:   "import [repo|url|file...]",
+		Short: "下载 GPG 密钥，指纹匹配后导入 rpm",
+		Example: `yuv key import docker
+  yuv key import https://example.com/RPM-GPG-KEY --fingerprint 0123456789ABCDEF0123456789ABCDEF01234567`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			fingerprints, _ := cmd.Flags().GetStringSlice("fingerprint")
+			sources, err := keySources(args, fingerprints)
+			if err != nil {
+				log.Fatalf("解析密钥来源失败: %v", err)
+			}
+			for _, src := range sources {
+				keys, err := keyMgr.Import(src)
+				if err != nil {
+					log.Fatalf("导入密钥失败: %v", err)
+				}
+				if len(keys) == 0 {
+					fmt.Printf("%s 的密钥已导入，无需重复导入\n", src.Name)
+				}
+				for _, k := range keys {
+					fmt.Printf("成功导入 %s 的密钥 %s (%s)\n", src.Name, key.FormatFingerprint(k.Fingerprint), k.UserID())
+				}
+			}
+		},
+	}
+	keyImportCmd.Flags().StringSlice("fingerprint", nil, "期望的密钥指纹，可多次指定")
+	keyCmd.AddCommand(keyImportCmd)
+
+	// key remove 命令
+	keyCmd.AddCommand(&cobra.Command{
+		Use:     "remove [fingerprint|keyid...]",
+		Short:   "从 rpm 中删除 GPG 密钥",
+		Example: "yuv key remove 621E9F35\n  yuv key remove gpg-pubkey-621e9f35-58adea78",
+		Args:    cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			packages, err := keyMgr.Remove(args...)
+			if err != nil {
+				log.Fatalf("删除密钥失败: %v", err)
+			}
+			for _, p := range packages {
+				fmt.Printf("成功删除密钥 %s\n", p)
+			}
+		},
+	})
+
+	// key verify 命令
+	keyVerifyCmd := &cobra.Command{
+		Use:     "verify [repo|url|file...]",
+		Short:   "校验 GPG 密钥的指纹和有效期，默认校验所有固定了指纹的预置仓库",
+		Example: "yuv key verify\n  yuv key verify nginx docker",
+		Run: func(cmd *cobra.Command, args []string) {
+			output, _ := cmd.Flags().GetString("output")
+			fingerprints, _ := cmd.Flags().GetStringSlice("fingerprint")
+			sources, err := keySources(args, fingerprints)
+			if err != nil {
+				log.Fatalf("解析密钥来源失败: %v", err)
+			}
+			var results []*key.Verification
+			for _, src := range sources {
+				results = append(results, keyMgr.Verify(src))
+			}
+
+			switch output {
+			case "json":
+				if err := printJSON(results); err != nil {
+					log.Fatalf("输出 JSON 失败: %v", err)
+				}
+			case "table":
+				printVerifications(results)
+			default:
+				log.Fatalf("不支持的输出格式: %s", output)
+			}
+
+			for _, r := range results {
+				if !r.OK() {
+					os.Exit(1)
+				}
+			}
+		},
+	}
+	keyVerifyCmd.Flags().StringSlice("fingerprint", nil, "期望的密钥指纹，可多次指定")
+	keyVerifyCmd.Flags().StringP("output", "o", "table", "输出格式: table 或 json")
+	keyCmd.AddCommand(keyVerifyCmd)
+
+	rootCmd.AddCommand(keyCmd)
+
+	// 模块流管理命令组
+	moduleCmd := &cobra.Command{
+		Use:   "module",
+		Short: "管理 DNF 模块流（EL8 及以后）",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	moduleListCmd := &cobra.Command{
+		Use:     "list [module...]",
+		Short:   "列出模块流及其默认、启用、禁用和安装状态",
+		Example: "yuv module list\n  yuv module list nodejs php --enabled\n  yuv module list -o json",
+		Run: func(cmd *cobra.Command, args []string) {
+			onlyEnabled, _ := cmd.Flags().GetBool("enabled")
+			onlyDisabled, _ := cmd.Flags().GetBool("disabled")
+			onlyInstalled, _ := cmd.Flags().GetBool("installed")
+			output, _ := cmd.Flags().GetString("output")
+
+			streams, err := packageMgr.ModuleList(args...)
+			if err != nil {
+				log.Fatalf("列出模块失败: %v", err)
+			}
+
+			// 按状态过滤
+			filtered := []*pkg.ModuleStream{}
+			for _, s := range streams {
+				if (onlyEnabled && !s.Enabled) || (onlyDisabled && !s.Disabled) || (onlyInstalled && len(s.Installed) == 0) {
+					continue
+				}
+				filtered = append(filtered, s)
+			}
+
+			switch output {
+			case "json":
+				if err := printJSON(filtered); err != nil {
+					log.Fatalf("输出 JSON 失败: %v", err)
+				}
+			case "table":
+				printModuleStreams(filtered)
+			default:
+				log.Fatalf("不支持的输出格式: %s", output)
+			}
+		},
+	}
+	moduleListCmd.Flags().Bool("enabled", false, "只显示已启用的模块流")
+	moduleListCmd.Flags().Bool("disabled", false, "只显示已禁用的模块流")
+	moduleListCmd.Flags().Bool("installed", false, "只显示安装了 profile 的模块流")
+	moduleListCmd.Flags().StringP("output", "o", "table", "输出格式: table 或 json")
+	moduleCmd.AddCommand(moduleListCmd)
+
+	moduleInfoCmd := &cobra.Command{
+		Use:     "info [module[:stream]]",
+		Short:   "查看模块流的详细信息（profile、依赖和软件包）",
+		Example: "yuv module info nodejs:18\n  yuv module info php -o json",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			output, _ := cmd.Flags().GetString("output")
+			infos, err := packageMgr.ModuleInfo(args[0])
+			if err != nil {
+				log.Fatalf("查看模块信息失败: %v", err)
+			}
+			switch output {
+			case "json":
+				if err := printJSON(infos); err != nil {
+					log.Fatalf("输出 JSON 失败: %v", err)
+				}
+			case "table":
+				printModuleInfos(infos)
+			default:
+				log.Fatalf("不支持的输出格式: %s", output)
+			}
+		},
+	}
+	moduleInfoCmd.Flags().StringP("output", "o", "table", "输出格式: table 或 json")
+	moduleCmd.AddCommand(moduleInfoCmd)
+
+	moduleCmd.AddCommand(&cobra.Command{
+		Use:     "enable [module[:stream]...]",
+		Short:   "启用模块流，未指定流时启用默认流",
+		Example: "yuv module enable nodejs:18\n  yuv module enable php:8.1 redis:6",
+		Args:    cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := packageMgr.ModuleEnable(args...); err != nil {
+				log.Fatalf("启用模块流失败: %v", err)
+			}
+			fmt.Printf("成功启用模块流 %s\n", strings.Join(args, " "))
+		},
+	})
+
+	moduleCmd.AddCommand(&cobra.Command{
+		Use:     "disable [module...]",
+		Short:   "禁用模块，使第三方仓库中的同名软件包不再被模块过滤",
+		Example: "yuv module disable mysql",
+		Args:    cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := packageMgr.ModuleDisable(args...); err != nil {
+				log.Fatalf("禁用模块失败: %v", err)
+			}
+			fmt.Printf("成功禁用模块 %s\n", strings.Join(args, " "))
+		},
+	})
+
+	moduleCmd.AddCommand(&cobra.Command{
+		Use:     "reset [module...]",
+		Short:   "把模块恢复到初始状态（既不启用也不禁用）",
+		Example: "yuv module reset nodejs",
+		Args:    cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := packageMgr.ModuleReset(args...); err != nil {
+				log.Fatalf("重置模块失败: %v", err)
+			}
+			fmt.Printf("成功重置模块 %s\n", strings.Join(args, " "))
+		},
+	})
+
+	moduleCmd.AddCommand(&cobra.Command{
+		Use:     "switch [module:stream]",
+		Short:   "切换到模块的另一个流，已安装的软件包同步到新流的版本",
+		Example: "yuv module switch nodejs:20",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := packageMgr.ModuleSwitch(args[0]); err != nil {
+				log.Fatalf("切换模块流失败: %v", err)
+			}
+			fmt.Printf("成功切换到模块流 %s\n", args[0])
+		},
+	})
+	rootCmd.AddCommand(moduleCmd)
+
 	// 直接添加中文的 completion 命令，覆盖默认的
 	rootCmd.AddCommand(&cobra.Command{
 		Use:   "completion",
@@ -358,3 +1133,104 @@ func main() {
 		log.Fatalf("执行命令失败: %v", err)
 	}
 }
+
+// keySources 把参数解析为密钥来源：预置仓库使用目录中的 gpgkey 和固定的指纹，
+// 其他参数视为密钥地址或本地文件；未指定参数时返回所有固定了指纹的预置仓库
+func keySources(args, fingerprints []string) ([]*key.Source, error) {
+	catalog, err := repo.DefaultCatalog()
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		for _, name := range catalog.Names() {
+			if len(catalog.Repos[name].Fingerprints) > 0 {
+				args = append(args, name)
+			}
+		}
+	}
+
+	var sources []*key.Source
+	for _, arg := range args {
+		if strings.Contains(arg, "://") {
+			sources = append(sources, &key.Source{Name: arg, URL: keyPath(arg), Fingerprints: fingerprints})
+			continue
+		}
+		path := keyPath(arg)
+		if _, err := os.Stat(path); err == nil {
+			sources = append(sources, &key.Source{Name: arg, URL: path, Fingerprints: fingerprints})
+			continue
+		}
+
+		r, err := catalog.Get(arg)
+		if err != nil {
+			return nil, err
+		}
+		distro, err := detector.GetDistroName()
+		if err != nil {
+			return nil, err
+		}
+		releasever, err := detector.GetReleasever()
+		if err != nil {
+			return nil, err
+		}
+		basearch, err := detector.GetBasearch()
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, &key.Source{
+			Name:         r.Name,
+			URL:          keyPath(r.GetGPGKeyURL(distro, releasever, basearch)),
+			Fingerprints: append(append([]string{}, r.Fingerprints...), fingerprints...),
+		})
+	}
+	return sources, nil
+}
+
+// keyPath 将本地密钥路径和 file:// 地址解析到备用根目录下
+func keyPath(path string) string {
+	if detector.Root == "" {
+		return path
+	}
+	if strings.HasPrefix(path, "file://") {
+		return "file://" + filepath.Join(detector.Root, strings.TrimPrefix(path, "file://"))
+	}
+	if strings.Contains(path, "://") {
+		return path
+	}
+	return filepath.Join(detector.Root, path)
+}
+
+// proxyFromFlags 根据 --proxy、--proxy-user、--proxy-password-stdin 生成仓库代理，未指定 --proxy 时返回 nil
+func proxyFromFlags(cmd *cobra.Command) *utils.Proxy {
+	url, _ := cmd.Flags().GetString("proxy")
+	if url == "" {
+		return nil
+	}
+	proxy := &utils.Proxy{URL: url}
+	if url == repo.ProxyNone {
+		proxy.URL = ""
+	}
+	proxy.Username, _ = cmd.Flags().GetString("proxy-user")
+	proxy.Password = proxyPassword(cmd, "proxy-password-stdin", proxy.Username)
+	return proxy
+}
+
+// proxyPassword 读取代理认证密码：指定了 stdinFlag 时从标准输入读取第一行，否则使用 YUV_PROXY_PASSWORD 环境变量；
+// 密码不通过命令行参数传递，避免出现在 shell 历史和 ps 中。没有用户名时不使用密码
+func proxyPassword(cmd *cobra.Command, stdinFlag, username string) string {
+	fromStdin, _ := cmd.Flags().GetBool(stdinFlag)
+	if username == "" {
+		if fromStdin {
+			log.Fatalf("--%s 需要同时指定代理认证用户名", stdinFlag)
+		}
+		return ""
+	}
+	if !fromStdin {
+		return os.Getenv("YUV_PROXY_PASSWORD")
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		log.Fatalf("读取代理认证密码失败: %v", err)
+	}
+	return strings.TrimRight(line, "\r\n")
+}
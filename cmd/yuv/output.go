@@ -0,0 +1,360 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"yuv/internal/utils"
+	"yuv/pkg/key"
+	pkg "yuv/pkg/pkgmgr"
+	"yuv/pkg/repo"
+)
+
+// printJSON 以缩进格式输出 JSON
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// printRepoTable 以表格形式输出仓库列表
+func printRepoTable(repos []*repo.RepoInfo) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tFILE\tENABLED\tURL\tGPGCHECK\tPRIORITY")
+	for _, r := range repos {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\n",
+			r.ID, r.Name, filepath.Base(r.File), yesNo(r.Enabled), r.URL(), yesNo(r.GPGCheck), r.Priority)
+	}
+	w.Flush()
+}
+
+// printVersions 以表格形式输出预置仓库的可用版本，STATUS 列标记默认版本和当前已添加的版本
+func printVersions(versions []*repo.VersionInfo) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REPO\tVERSION\tSTATUS")
+	for _, v := range versions {
+		var status []string
+		if v.Default {
+			status = append(status, "默认")
+		}
+		if v.Current {
+			status = append(status, "当前")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", v.Repo, v.Version, orDash(strings.Join(status, ",")))
+	}
+	w.Flush()
+}
+
+// printProxies 输出全局代理和单独设置了代理的仓库，隐藏密码
+func printProxies(global *utils.Proxy, repos []*repo.RepoInfo) {
+	if global == nil {
+		fmt.Println("全局代理: 未设置（使用环境变量中的代理）")
+	} else {
+		fmt.Printf("全局代理: %s\n", global.Redacted())
+		if global.Username != "" {
+			fmt.Printf("认证用户: %s\n", global.Username)
+		}
+		if len(global.NoProxy) > 0 {
+			fmt.Printf("不使用代理: %s\n", strings.Join(global.NoProxy, ", "))
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	header := false
+	for _, r := range repos {
+		if r.Proxy == "" {
+			continue
+		}
+		if !header {
+			fmt.Println()
+			fmt.Fprintln(w, "ID\tFILE\tPROXY")
+			header = true
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", r.ID, filepath.Base(r.File), r.Proxy)
+	}
+	w.Flush()
+}
+
+// printCheckResults 输出仓库检查结果
+func printCheckResults(results []*repo.CheckResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATUS\tURL\tDETAIL")
+	for _, r := range results {
+		if r.OK {
+			fmt.Fprintf(w, "%s\tOK\t%s\trevision %s\n", r.ID, r.URL, r.Revision)
+			continue
+		}
+		fmt.Fprintf(w, "%s\tFAIL\t%s\t%s\n", r.ID, r.URL, strings.Join(r.Problems, "; "))
+	}
+	w.Flush()
+}
+
+// printSnapshots 输出备份快照列表
+func printSnapshots(snapshots []*repo.Snapshot) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCREATED\tFILES\tPINNED\tCOMMAND")
+	for _, s := range snapshots {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", s.ID, s.Created.Format("2006-01-02 15:04:05"), len(s.Files), yesNo(s.Pinned), s.Command)
+	}
+	w.Flush()
+}
+
+// printFileChanges 输出文件变更摘要
+func printFileChanges(changes []*repo.FileChange) {
+	for _, c := range changes {
+		switch c.Action {
+		case repo.ChangeCreate:
+			fmt.Printf("  + 新建 %s\n", c.Name)
+		case repo.ChangeModify:
+			fmt.Printf("  ~ 修改 %s\n", c.Name)
+		case repo.ChangeDelete:
+			fmt.Printf("  - 删除 %s\n", c.Name)
+		case repo.ChangeMode:
+			fmt.Printf("  ~ 权限 %s %04o -> %04o\n", c.Name, c.OldMode, c.Mode)
+		}
+	}
+}
+
+// printPlans 输出 --dry-run 的预览：文件变更摘要、每个文件的统一 diff 和将要执行的命令
+func printPlans(plans []*repo.Plan) {
+	var changes []*repo.FileChange
+	var commands []*repo.Command
+	for _, plan := range plans {
+		changes = append(changes, plan.Changes...)
+		commands = append(commands, plan.Commands...)
+	}
+	if len(changes) == 0 && len(commands) == 0 {
+		fmt.Println("没有需要进行的变更")
+		return
+	}
+
+	if len(changes) > 0 {
+		fmt.Println("将要进行的文件变更:")
+		printFileChanges(changes)
+		for _, c := range changes {
+			if c.Diff != "" {
+				fmt.Println()
+				fmt.Print(c.Diff)
+			}
+		}
+	}
+	if len(commands) > 0 {
+		if len(changes) > 0 {
+			fmt.Println()
+		}
+		fmt.Println("将要执行的命令:")
+		for _, c := range commands {
+			if c.IgnoreError {
+				fmt.Printf("  %s （失败时忽略）\n", c)
+				continue
+			}
+			fmt.Printf("  %s\n", c)
+		}
+	}
+	fmt.Println()
+	fmt.Println("预览模式，未做任何修改")
+}
+
+// printFindings 输出仓库诊断结果
+func printFindings(findings []*repo.Finding) {
+	if len(findings) == 0 {
+		fmt.Println("未发现问题")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SEVERITY\tCODE\tREPO\tFILE\tMESSAGE\tFIX")
+	errors, warnings, fixable := 0, 0, 0
+	for _, f := range findings {
+		if f.Severity == repo.SeverityError {
+			errors++
+		} else {
+			warnings++
+		}
+		fix := f.Fix
+		switch {
+		case f.Fixed:
+			fix = "已修复: " + f.Fix
+		case f.Fix != "":
+			fixable++
+		case fix == "":
+			fix = "-"
+		}
+		repoID := f.Repo
+		if repoID == "" {
+			repoID = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", f.Severity, f.Code, repoID, filepath.Base(f.File), f.Message, fix)
+	}
+	w.Flush()
+	fmt.Printf("\n发现 %d 个问题（%d 个错误，%d 个警告）\n", len(findings), errors, warnings)
+	if fixable > 0 {
+		fmt.Printf("其中 %d 个可以使用 --fix 自动修复\n", fixable)
+	}
+}
+
+// printEOLFixes 输出迁移到 vault 的仓库
+func printEOLFixes(fixes []*repo.EOLFix) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tFILE\tFROM\tTO")
+	for _, f := range fixes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", f.ID, filepath.Base(f.File), f.From, f.To)
+	}
+	w.Flush()
+}
+
+// printBenchResults 输出镜像源测速排名
+func printBenchResults(results []*repo.BenchResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RANK\tMIRROR\tLATENCY\tSPEED\tURL")
+	for i, r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(w, "-\t%s\t-\t-\t%s (%v)\n", r.Name, r.URL, r.Err)
+			continue
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n",
+			i+1, r.Name, r.Latency.Round(time.Millisecond), formatSpeed(r.Throughput), r.URL)
+	}
+	w.Flush()
+}
+
+// formatSpeed 格式化下载速度
+func formatSpeed(bytesPerSecond float64) string {
+	switch {
+	case bytesPerSecond >= 1<<20:
+		return fmt.Sprintf("%.2f MB/s", bytesPerSecond/(1<<20))
+	case bytesPerSecond >= 1<<10:
+		return fmt.Sprintf("%.2f KB/s", bytesPerSecond/(1<<10))
+	default:
+		return fmt.Sprintf("%.0f B/s", bytesPerSecond)
+	}
+}
+
+// yesNo 将布尔值转换为 yes/no
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// printInstalledKeys 输出已导入 rpm 的密钥
+func printInstalledKeys(keys []*key.InstalledKey) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE\tFINGERPRINT\tALGO\tCREATED\tEXPIRES\tUSER ID")
+	for _, k := range keys {
+		if k.Key == nil {
+			fmt.Fprintf(w, "%s\t-\t-\t-\t-\t(%s)\n", k.Package, k.Error)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			k.Package, k.Fingerprint, k.Algorithm, k.Created.Format("2006-01-02"), formatExpires(k.Key), k.UserID())
+	}
+	w.Flush()
+}
+
+// printVerifications 输出密钥校验结果
+func printVerifications(results []*key.Verification) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SOURCE\tFINGERPRINT\tEXPIRES\tSTATUS\tUSER ID")
+	for _, r := range results {
+		if r.Error != "" {
+			fmt.Fprintf(w, "%s\t-\t-\tFAIL\t%s (%s)\n", r.Source, r.URL, r.Error)
+			continue
+		}
+		for _, k := range r.Keys {
+			var status []string
+			switch {
+			case !k.Pinned:
+				status = append(status, "未固定")
+			case k.Revoked:
+				status = append(status, "已吊销")
+			case k.Expired(time.Now()):
+				status = append(status, "已过期")
+			default:
+				status = append(status, "指纹匹配")
+			}
+			if k.Imported {
+				status = append(status, "已导入")
+			} else {
+				status = append(status, "未导入")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+				r.Source, key.FormatFingerprint(k.Fingerprint), formatExpires(k.Key), strings.Join(status, ","), k.UserID())
+		}
+	}
+	w.Flush()
+}
+
+// formatExpires 格式化密钥过期时间
+func formatExpires(k *key.Key) string {
+	if k.Expires.IsZero() {
+		return "never"
+	}
+	return k.Expires.Format("2006-01-02")
+}
+
+// printModuleStreams 以表格形式输出模块流，STATUS 列依次为默认、启用、禁用标记
+func printModuleStreams(streams []*pkg.ModuleStream) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTREAM\tSTATUS\tPROFILES\tINSTALLED\tREPO")
+	for _, s := range streams {
+		var status []string
+		if s.Default {
+			status = append(status, "默认")
+		}
+		if s.Enabled {
+			status = append(status, "已启用")
+		}
+		if s.Disabled {
+			status = append(status, "已禁用")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", s.Name, s.Stream, orDash(strings.Join(status, ",")),
+			orDash(strings.Join(s.Profiles, ",")), orDash(strings.Join(s.Installed, ",")), s.Repo)
+	}
+	w.Flush()
+}
+
+// printModuleInfos 输出模块流的详细信息，每个版本一段
+func printModuleInfos(infos []*pkg.ModuleInfo) {
+	for i, info := range infos {
+		if i > 0 {
+			fmt.Println()
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "名称:\t%s\n", info.Name)
+		fmt.Fprintf(w, "流:\t%s (默认: %s, 启用: %s)\n", info.Stream, yesNo(info.Default), yesNo(info.Enabled))
+		fmt.Fprintf(w, "版本:\t%s\n", orDash(strings.Join(nonEmpty(info.Version, info.Context, info.Arch), " ")))
+		fmt.Fprintf(w, "仓库:\t%s\n", orDash(info.Repo))
+		fmt.Fprintf(w, "Profiles:\t%s\n", orDash(strings.Join(info.Profiles, ", ")))
+		fmt.Fprintf(w, "默认 profile:\t%s\n", orDash(strings.Join(info.DefaultProfiles, ", ")))
+		fmt.Fprintf(w, "已安装:\t%s\n", orDash(strings.Join(info.Installed, ", ")))
+		fmt.Fprintf(w, "依赖:\t%s\n", orDash(strings.Join(info.Requires, ", ")))
+		fmt.Fprintf(w, "简介:\t%s\n", orDash(info.Summary))
+		fmt.Fprintf(w, "软件包:\t%d 个\n", len(info.Artifacts))
+		w.Flush()
+	}
+}
+
+// orDash 空字符串显示为 -
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// nonEmpty 过滤空字符串
+func nonEmpty(values ...string) []string {
+	var result []string
+	for _, v := range values {
+		if v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
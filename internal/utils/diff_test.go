@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numbered 生成 l1 到 ln 的行，replace 中的行号替换为指定内容
+func numbered(n int, replace map[int]string) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		if line, ok := replace[i]; ok {
+			b.WriteString(line + "\n")
+		} else {
+			fmt.Fprintf(&b, "l%d\n", i)
+		}
+	}
+	return b.String()
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want string
+	}{
+		{
+			name: "内容相同",
+			old:  "a\nb\n",
+			new:  "a\nb\n",
+			want: "",
+		},
+		{
+			name: "新建文件",
+			old:  "",
+			new:  "a\nb\n",
+			want: "--- a/x.repo\n+++ b/x.repo\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "删除全部内容",
+			old:  "a\nb\n",
+			new:  "",
+			want: "--- a/x.repo\n+++ b/x.repo\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name: "只插入行",
+			old:  numbered(8, nil),
+			new:  numbered(8, map[int]string{5: "l5\nnew"}),
+			want: "--- a/x.repo\n+++ b/x.repo\n@@ -3,6 +3,7 @@\n l3\n l4\n l5\n+new\n l6\n l7\n l8\n",
+		},
+		{
+			name: "只删除行",
+			old:  numbered(8, nil),
+			new:  strings.Replace(numbered(8, nil), "l5\n", "", 1),
+			want: "--- a/x.repo\n+++ b/x.repo\n@@ -2,7 +2,6 @@\n l2\n l3\n l4\n-l5\n l6\n l7\n l8\n",
+		},
+		{
+			name: "修改第一行",
+			old:  numbered(8, nil),
+			new:  numbered(8, map[int]string{1: "first"}),
+			want: "--- a/x.repo\n+++ b/x.repo\n@@ -1,4 +1,4 @@\n-l1\n+first\n l2\n l3\n l4\n",
+		},
+		{
+			name: "修改最后一行",
+			old:  numbered(8, nil),
+			new:  numbered(8, map[int]string{8: "last"}),
+			want: "--- a/x.repo\n+++ b/x.repo\n@@ -5,4 +5,4 @@\n l5\n l6\n l7\n-l8\n+last\n",
+		},
+		{
+			name: "旧文件没有结尾换行",
+			old:  "a\nb",
+			new:  "a\nb\n",
+			want: "--- a/x.repo\n+++ b/x.repo\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		{
+			name: "新文件没有结尾换行",
+			old:  "a\n",
+			new:  "a\nb",
+			want: "--- a/x.repo\n+++ b/x.repo\n@@ -1,1 +1,2 @@\n a\n+b\n\\ No newline at end of file\n",
+		},
+		{
+			name: "相隔超过两倍上下文时分为两个变更块",
+			old:  numbered(20, nil),
+			new:  numbered(20, map[int]string{2: "x", 19: "y"}),
+			want: "--- a/x.repo\n+++ b/x.repo\n" +
+				"@@ -1,5 +1,5 @@\n l1\n-l2\n+x\n l3\n l4\n l5\n" +
+				"@@ -16,5 +16,5 @@\n l16\n l17\n l18\n-l19\n+y\n l20\n",
+		},
+		{
+			name: "相隔两倍上下文时合并为一个变更块",
+			old:  numbered(12, nil),
+			new:  numbered(12, map[int]string{2: "x", 9: "y"}),
+			want: "--- a/x.repo\n+++ b/x.repo\n" +
+				"@@ -1,12 +1,12 @@\n l1\n-l2\n+x\n l3\n l4\n l5\n l6\n l7\n l8\n-l9\n+y\n l10\n l11\n l12\n",
+		},
+		{
+			name: "第二个变更块的行号计入前面的增删",
+			old:  numbered(20, nil),
+			new:  numbered(20, map[int]string{2: "x\nx2\nx3", 18: "y"}),
+			want: "--- a/x.repo\n+++ b/x.repo\n" +
+				"@@ -1,5 +1,7 @@\n l1\n-l2\n+x\n+x2\n+x3\n l3\n l4\n l5\n" +
+				"@@ -15,6 +17,6 @@\n l15\n l16\n l17\n-l18\n+y\n l19\n l20\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := UnifiedDiff("a/x.repo", "b/x.repo", []byte(tt.old), []byte(tt.new))
+			if got != tt.want {
+				t.Errorf("UnifiedDiff() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"", nil},
+		{"a", []string{"a"}},
+		{"a\n", []string{"a\n"}},
+		{"a\n\nb", []string{"a\n", "\n", "b"}},
+		{"a\r\nb\r\n", []string{"a\r\n", "b\r\n"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%q", tt.input), func(t *testing.T) {
+			got := splitLines(tt.input)
+			if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", tt.want) {
+				t.Errorf("splitLines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
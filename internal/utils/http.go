@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Proxy HTTP 代理配置
+type Proxy struct {
+	URL      string   `yaml:"url" json:"url"`                               // 代理地址，为空表示不使用代理
+	Username string   `yaml:"username,omitempty" json:"username,omitempty"` // 代理认证用户名
+	Password string   `yaml:"password,omitempty" json:"password,omitempty"` // 代理认证密码
+	NoProxy  []string `yaml:"no_proxy,omitempty" json:"no_proxy,omitempty"` // 不使用代理的主机、域名后缀或网段
+}
+
+// Validate 校验代理地址，支持 dnf 可用的 http、https、socks4、socks5 代理
+func (p *Proxy) Validate() error {
+	if p.URL == "" {
+		return nil
+	}
+	u, err := url.Parse(p.URL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid proxy url %q", p.URL)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks4", "socks4a", "socks5", "socks5h":
+	default:
+		return fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	if p.Password != "" && p.Username == "" {
+		return fmt.Errorf("proxy password requires a username")
+	}
+	return nil
+}
+
+// Redacted 返回隐藏了密码的代理地址，用于显示
+func (p *Proxy) Redacted() string {
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return p.URL
+	}
+	return u.Redacted()
+}
+
+// Bypass 主机是否在 no_proxy 列表中：* 匹配所有主机，域名匹配自身及子域名，IP 地址和网段按地址匹配
+func (p *Proxy) Bypass(host string) bool {
+	host = strings.ToLower(strings.Trim(host, "[]"))
+	ip := net.ParseIP(host)
+	for _, entry := range p.NoProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+		case entry == "*":
+			return true
+		case strings.Contains(entry, "/"):
+			if _, network, err := net.ParseCIDR(entry); err == nil && ip != nil && network.Contains(ip) {
+				return true
+			}
+		default:
+			entry = strings.TrimPrefix(entry, ".")
+			if host == entry || strings.HasSuffix(host, "."+entry) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// proxyFunc 返回 http.Transport 使用的代理函数，p 为 nil 时使用环境变量中的代理
+func (p *Proxy) proxyFunc() func(*http.Request) (*url.URL, error) {
+	if p == nil {
+		return http.ProxyFromEnvironment
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		if p.URL == "" || p.Bypass(req.URL.Hostname()) {
+			return nil, nil
+		}
+		u, err := url.Parse(p.URL)
+		if err != nil {
+			return nil, err
+		}
+		if u.User == nil && p.Username != "" {
+			u.User = url.UserPassword(p.Username, p.Password)
+		}
+		return u, nil
+	}
+}
+
+// Client yuv 自身发起 HTTP 请求使用的客户端
+type Client struct {
+	http *http.Client
+}
+
+// NewClient 创建通过代理访问的客户端，p 为 nil 时使用环境变量中的代理，URL 为空时直接访问
+func NewClient(p *Proxy) *Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = p.proxyFunc()
+	return &Client{http: &http.Client{Timeout: 60 * time.Second, Transport: transport}}
+}
+
+// DefaultClient 默认客户端，通过 SetProxy 设置全局代理
+var DefaultClient = NewClient(nil)
+
+// SetProxy 设置默认客户端的全局代理，p 为 nil 时恢复使用环境变量中的代理
+func SetProxy(p *Proxy) {
+	DefaultClient = NewClient(p)
+}
+
+// Get 发起 GET 请求，非 2xx 状态码视为错误，调用方负责关闭 Body
+func (c *Client) Get(url string) (*http.Response, error) {
+	resp, err := c.http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return resp, nil
+}
+
+// Open 打开 http(s) 或 file:// 地址，调用方负责关闭
+func (c *Client) Open(rawURL string) (io.ReadCloser, error) {
+	if strings.HasPrefix(rawURL, "file://") {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		return os.Open(u.Path)
+	}
+	resp, err := c.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Fetch 下载地址的完整内容，支持 http(s) 和 file://
+func (c *Client) Fetch(url string) ([]byte, error) {
+	body, err := c.Open(url)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return ioutil.ReadAll(body)
+}
+
+// FetchLimit 最多下载 limit 字节，用于探测
+func (c *Client) FetchLimit(url string, limit int64) ([]byte, error) {
+	body, err := c.Open(url)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return ioutil.ReadAll(io.LimitReader(body, limit))
+}
+
+// Get 使用默认客户端发起 GET 请求
+func Get(url string) (*http.Response, error) {
+	return DefaultClient.Get(url)
+}
+
+// Open 使用默认客户端打开 http(s) 或 file:// 地址
+func Open(rawURL string) (io.ReadCloser, error) {
+	return DefaultClient.Open(rawURL)
+}
+
+// Fetch 使用默认客户端下载地址的完整内容
+func Fetch(url string) ([]byte, error) {
+	return DefaultClient.Fetch(url)
+}
+
+// FetchLimit 使用默认客户端最多下载 limit 字节
+func FetchLimit(url string, limit int64) ([]byte, error) {
+	return DefaultClient.FetchLimit(url, limit)
+}
@@ -0,0 +1,260 @@
+package key
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"yuv/internal/utils"
+)
+
+// Manager GPG 密钥管理器，密钥导入 rpm 公钥环（gpg-pubkey 软件包）
+type Manager struct {
+	Root string // 备用根目录，通过 --root 传给 rpm
+}
+
+// NewManager 创建密钥管理器实例
+func NewManager() *Manager {
+	return &Manager{}
+}
+
+// rpm 构建 rpm 命令，设置了 Root 时加上 --root
+func (m *Manager) rpm(args ...string) *exec.Cmd {
+	if m.Root != "" {
+		args = append([]string{"--root", m.Root}, args...)
+	}
+	return exec.Command("rpm", args...)
+}
+
+// Source 密钥来源及期望的指纹
+type Source struct {
+	Name         string   // 源名称或密钥地址
+	URL          string   // 密钥地址，支持 http(s)、file:// 和本地路径
+	Fingerprints []string // 固定的指纹，只有匹配的密钥才会导入
+}
+
+// pinned 判断指纹是否已固定
+func (s *Source) pinned(fingerprint string) bool {
+	for _, fp := range s.Fingerprints {
+		if NormalizeFingerprint(fp) == fingerprint {
+			return true
+		}
+	}
+	return false
+}
+
+// InstalledKey 已导入 rpm 的公钥
+type InstalledKey struct {
+	Package string `json:"package"` // gpg-pubkey-<短 ID>-<创建时间>
+	*Key
+	Error string `json:"error,omitempty"` // 无法解析时的原因
+}
+
+// KeyStatus 密钥文件中单个密钥的校验状态
+type KeyStatus struct {
+	*Key
+	Pinned   bool `json:"pinned"`   // 指纹与固定值一致
+	Imported bool `json:"imported"` // 已导入 rpm
+}
+
+// Usable 密钥指纹匹配且未过期、未吊销
+func (s *KeyStatus) Usable() bool {
+	return s.Pinned && !s.Revoked && !s.Expired(time.Now())
+}
+
+// Verification 一个密钥来源的校验结果
+type Verification struct {
+	Source string       `json:"source"`
+	URL    string       `json:"url"`
+	Keys   []*KeyStatus `json:"keys,omitempty"`
+	Error  string       `json:"error,omitempty"` // 下载或解析失败的原因
+}
+
+// OK 至少有一个指纹匹配且有效的密钥
+func (v *Verification) OK() bool {
+	if v.Error != "" {
+		return false
+	}
+	for _, k := range v.Keys {
+		if k.Usable() {
+			return true
+		}
+	}
+	return false
+}
+
+// List 列出已导入 rpm 的公钥
+func (m *Manager) List() ([]*InstalledKey, error) {
+	output, err := m.rpm("-q", "gpg-pubkey", "--qf", "@@%{NAME}-%{VERSION}-%{RELEASE}\n%{DESCRIPTION}\n").Output()
+	if err != nil {
+		// 没有导入任何密钥时 rpm 返回非零
+		if exitErr, ok := err.(*exec.ExitError); ok && strings.Contains(string(output)+string(exitErr.Stderr), "is not installed") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("query rpm keyring failed: %v", err)
+	}
+	return parseInstalled(string(output)), nil
+}
+
+// parseInstalled 解析 rpm -q gpg-pubkey 的输出，每个密钥以 @@ 开头，包名之后是 armor 格式的公钥
+func parseInstalled(output string) []*InstalledKey {
+	var installed []*InstalledKey
+	for _, entry := range strings.Split(output, "@@") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		lines := strings.SplitN(entry, "\n", 2)
+		key := &InstalledKey{Package: strings.TrimSpace(lines[0])}
+		if len(lines) < 2 {
+			key.Error = "no key data"
+		} else if keys, err := ParseKeys([]byte(lines[1])); err != nil {
+			key.Error = err.Error()
+		} else {
+			key.Key = keys[0]
+		}
+		installed = append(installed, key)
+	}
+	return installed
+}
+
+// Fetch 下载并解析密钥文件
+func Fetch(url string) ([]*Key, error) {
+	var content []byte
+	var err error
+	if strings.Contains(url, "://") {
+		content, err = utils.Fetch(url)
+	} else {
+		content, err = ioutil.ReadFile(url)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ParseKeys(content)
+}
+
+// Verify 下载密钥并与固定的指纹比对，同时检查是否已导入 rpm
+func (m *Manager) Verify(src *Source) *Verification {
+	v := &Verification{Source: src.Name, URL: src.URL}
+	if src.URL == "" {
+		v.Error = "no gpgkey configured"
+		return v
+	}
+	keys, err := Fetch(src.URL)
+	if err != nil {
+		v.Error = err.Error()
+		return v
+	}
+
+	installed, err := m.List()
+	if err != nil {
+		v.Error = err.Error()
+		return v
+	}
+	imported := map[string]bool{}
+	for _, k := range installed {
+		if k.Key != nil {
+			imported[k.Fingerprint] = true
+		}
+	}
+
+	for _, k := range keys {
+		v.Keys = append(v.Keys, &KeyStatus{Key: k, Pinned: src.pinned(k.Fingerprint), Imported: imported[k.Fingerprint]})
+	}
+	return v
+}
+
+// Import 校验指纹后把匹配的密钥导入 rpm，未固定的密钥不会导入，返回新导入的密钥
+func (m *Manager) Import(src *Source) ([]*Key, error) {
+	v := m.Verify(src)
+	if v.Error != "" {
+		return nil, fmt.Errorf("%s: %s", src.Name, v.Error)
+	}
+
+	var found []string
+	for _, k := range v.Keys {
+		found = append(found, k.Fingerprint)
+	}
+	if len(src.Fingerprints) == 0 {
+		return nil, fmt.Errorf("%s: no fingerprint pinned (key file contains %s)", src.Name, strings.Join(found, ", "))
+	}
+	if !v.OK() {
+		for _, k := range v.Keys {
+			if k.Pinned && k.Revoked {
+				return nil, fmt.Errorf("%s: key %s is revoked", src.Name, k.Fingerprint)
+			}
+			if k.Pinned && k.Expired(time.Now()) {
+				return nil, fmt.Errorf("%s: key %s expired at %s", src.Name, k.Fingerprint, k.Expires.Format("2006-01-02"))
+			}
+		}
+		return nil, fmt.Errorf("%s: no key matches the pinned fingerprints (key file contains %s)", src.Name, strings.Join(found, ", "))
+	}
+
+	var keys []*Key
+	for _, k := range v.Keys {
+		if !k.Usable() || k.Imported {
+			continue
+		}
+		if err := m.importKey(k.Key); err != nil {
+			return keys, err
+		}
+		keys = append(keys, k.Key)
+	}
+	return keys, nil
+}
+
+// importKey 只导出单个密钥后调用 rpm --import，避免同一文件中未固定的密钥被一起导入
+func (m *Manager) importKey(k *Key) error {
+	dir, err := ioutil.TempDir("", "yuv-key-")
+	if err != nil {
+		return fmt.Errorf("create temp directory failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, k.KeyID+".asc")
+	if err := ioutil.WriteFile(path, k.Armor(), 0644); err != nil {
+		return fmt.Errorf("write key file failed: %v", err)
+	}
+	if output, err := m.rpm("--import", path).CombinedOutput(); err != nil {
+		return fmt.Errorf("rpm --import %s failed: %v: %s", k.Fingerprint, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// Remove 从 rpm 中删除密钥，支持指纹、Key ID（16 位或 8 位）和 gpg-pubkey 包名，返回删除的包名
+func (m *Manager) Remove(ids ...string) ([]string, error) {
+	installed, err := m.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var packages []string
+	seen := map[string]bool{}
+	for _, id := range ids {
+		normalized := NormalizeFingerprint(strings.TrimPrefix(strings.ToLower(id), "0x"))
+		var matched []string
+		for _, k := range installed {
+			if strings.EqualFold(k.Package, id) || (k.Key != nil && len(normalized) >= 8 && strings.HasSuffix(k.Fingerprint, normalized)) {
+				matched = append(matched, k.Package)
+			}
+		}
+		if len(matched) == 0 {
+			return nil, fmt.Errorf("key %s not found in rpm keyring", id)
+		}
+		for _, pkg := range matched {
+			if !seen[pkg] {
+				seen[pkg] = true
+				packages = append(packages, pkg)
+			}
+		}
+	}
+
+	if output, err := m.rpm(append([]string{"-e", "--allmatches"}, packages...)...).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("rpm -e failed: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return packages, nil
+}
@@ -0,0 +1,535 @@
+package key
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	armorBegin = "-----BEGIN PGP PUBLIC KEY BLOCK-----"
+	armorEnd   = "-----END PGP PUBLIC KEY BLOCK-----"
+)
+
+// OpenPGP 数据包类型
+const (
+	tagSignature    = 2
+	tagPublicKey    = 6
+	tagTrust        = 12
+	tagUserID       = 13
+	tagPublicSubkey = 14
+)
+
+// 签名类型
+const (
+	sigCertGeneric   = 0x10
+	sigCertPositive  = 0x13
+	sigDirectKey     = 0x1f
+	sigKeyRevocation = 0x20
+)
+
+// 签名子包类型
+const (
+	subpacketCreated   = 2
+	subpacketKeyExpiry = 9
+	subpacketIssuer    = 16
+	subpacketIssuerFP  = 33
+)
+
+// Key OpenPGP 公钥（主密钥及其用户 ID、签名和子密钥）
+type Key struct {
+	Fingerprint string    `json:"fingerprint"`       // 40 位大写十六进制指纹
+	KeyID       string    `json:"key_id"`            // 指纹的后 16 位
+	Algorithm   string    `json:"algorithm"`         // 如 rsa4096、ed25519
+	Created     time.Time `json:"created"`           // 创建时间
+	Expires     time.Time `json:"expires,omitzero"`  // 过期时间，零值表示永不过期
+	Revoked     bool      `json:"revoked,omitempty"` // 已被吊销
+	UserIDs     []string  `json:"user_ids"`
+	Subkeys     int       `json:"subkeys"`
+
+	packets []byte // 原始数据包（不含 trust 包），用于单独导出
+}
+
+// Expired 判断密钥在指定时间是否已过期
+func (k *Key) Expired(now time.Time) bool {
+	return !k.Expires.IsZero() && now.After(k.Expires)
+}
+
+// UserID 返回第一个用户 ID
+func (k *Key) UserID() string {
+	if len(k.UserIDs) == 0 {
+		return ""
+	}
+	return k.UserIDs[0]
+}
+
+// RPMName 密钥导入 rpm 后对应的软件包名：gpg-pubkey-<短 ID>-<创建时间>
+func (k *Key) RPMName() string {
+	return fmt.Sprintf("gpg-pubkey-%s-%08x", strings.ToLower(k.KeyID[8:]), k.Created.Unix())
+}
+
+// Armor 将密钥导出为 ASCII armor 格式
+func (k *Key) Armor() []byte {
+	var out bytes.Buffer
+	out.WriteString(armorBegin + "\n\n")
+	encoded := base64.StdEncoding.EncodeToString(k.packets)
+	for len(encoded) > 64 {
+		out.WriteString(encoded[:64] + "\n")
+		encoded = encoded[64:]
+	}
+	out.WriteString(encoded + "\n")
+	crc := crc24(k.packets)
+	out.WriteString("=" + base64.StdEncoding.EncodeToString([]byte{byte(crc >> 16), byte(crc >> 8), byte(crc)}) + "\n")
+	out.WriteString(armorEnd + "\n")
+	return out.Bytes()
+}
+
+// NormalizeFingerprint 去掉指纹中的空格并转换为大写
+func NormalizeFingerprint(fingerprint string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(fingerprint), ""))
+}
+
+// FormatFingerprint 按 4 位一组格式化指纹，便于阅读
+func FormatFingerprint(fingerprint string) string {
+	var groups []string
+	for i := 0; i < len(fingerprint); i += 4 {
+		end := i + 4
+		if end > len(fingerprint) {
+			end = len(fingerprint)
+		}
+		groups = append(groups, fingerprint[i:end])
+	}
+	return strings.Join(groups, " ")
+}
+
+// ParseKeys 解析公钥文件，支持 ASCII armor（可包含多个块）和二进制格式
+func ParseKeys(data []byte) ([]*Key, error) {
+	if !bytes.Contains(data, []byte(armorBegin)) {
+		return parsePackets(data)
+	}
+
+	var keys []*Key
+	rest := string(data)
+	for {
+		start := strings.Index(rest, armorBegin)
+		if start < 0 {
+			break
+		}
+		block, err := decodeArmor(rest[start:])
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := parsePackets(block)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, parsed...)
+
+		end := strings.Index(rest[start:], armorEnd)
+		if end < 0 {
+			break
+		}
+		rest = rest[start+end+len(armorEnd):]
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no public key found")
+	}
+	return keys, nil
+}
+
+// decodeArmor 解码从 BEGIN 行开始的一个 armor 块，并校验 CRC24
+func decodeArmor(text string) ([]byte, error) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")[1:]
+
+	// 跳过 Version、Comment 等头部，头部与数据之间以空行分隔
+	i := 0
+	for i < len(lines) && strings.Contains(lines[i], ": ") {
+		i++
+	}
+	if i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+		i++
+	}
+
+	var body strings.Builder
+	checksum := ""
+	ended := false
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == armorEnd {
+			ended = true
+			break
+		}
+		if strings.HasPrefix(line, "=") && len(line) == 5 {
+			checksum = line[1:]
+			continue
+		}
+		body.WriteString(line)
+	}
+	if !ended {
+		return nil, fmt.Errorf("armor: missing end line")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(body.String())
+	if err != nil {
+		return nil, fmt.Errorf("armor: %v", err)
+	}
+	// 校验和是可选的
+	if checksum != "" {
+		expected, err := base64.StdEncoding.DecodeString(checksum)
+		if err != nil || len(expected) != 3 {
+			return nil, fmt.Errorf("armor: invalid checksum %q", checksum)
+		}
+		if crc := crc24(data); uint32(expected[0])<<16|uint32(expected[1])<<8|uint32(expected[2]) != crc {
+			return nil, fmt.Errorf("armor: checksum mismatch")
+		}
+	}
+	return data, nil
+}
+
+// crc24 计算 RFC 4880 6.1 定义的 CRC24
+func crc24(data []byte) uint32 {
+	crc := uint32(0xB704CE)
+	for _, b := range data {
+		crc ^= uint32(b) << 16
+		for i := 0; i < 8; i++ {
+			crc <<= 1
+			if crc&0x1000000 != 0 {
+				crc ^= 0x1864CFB
+			}
+		}
+	}
+	return crc & 0xFFFFFF
+}
+
+// packet 一个 OpenPGP 数据包
+type packet struct {
+	tag  int
+	body []byte
+	raw  []byte // 包含包头的完整数据
+}
+
+// readPacket 读取一个数据包，支持新旧两种包头格式
+func readPacket(data []byte) (*packet, int, error) {
+	if len(data) < 2 || data[0]&0x80 == 0 {
+		return nil, 0, fmt.Errorf("invalid packet header")
+	}
+
+	var tag, offset, length int
+	if data[0]&0x40 != 0 {
+		// 新格式
+		tag = int(data[0] & 0x3f)
+		first := int(data[1])
+		switch {
+		case first < 192:
+			offset, length = 2, first
+		case first < 224:
+			if len(data) < 3 {
+				return nil, 0, fmt.Errorf("truncated packet header")
+			}
+			offset, length = 3, (first-192)<<8+int(data[2])+192
+		case first == 255:
+			if len(data) < 6 {
+				return nil, 0, fmt.Errorf("truncated packet header")
+			}
+			offset, length = 6, int(binary.BigEndian.Uint32(data[2:6]))
+		default:
+			return nil, 0, fmt.Errorf("partial body length is not allowed in public keys")
+		}
+	} else {
+		// 旧格式
+		tag = int(data[0]>>2) & 0x0f
+		switch data[0] & 0x03 {
+		case 0:
+			offset, length = 2, int(data[1])
+		case 1:
+			if len(data) < 3 {
+				return nil, 0, fmt.Errorf("truncated packet header")
+			}
+			offset, length = 3, int(binary.BigEndian.Uint16(data[1:3]))
+		case 2:
+			if len(data) < 5 {
+				return nil, 0, fmt.Errorf("truncated packet header")
+			}
+			offset, length = 5, int(binary.BigEndian.Uint32(data[1:5]))
+		default:
+			offset, length = 1, len(data)-1
+		}
+	}
+
+	if length < 0 || offset+length > len(data) {
+		return nil, 0, fmt.Errorf("truncated packet (tag %d)", tag)
+	}
+	end := offset + length
+	return &packet{tag: tag, body: data[offset:end], raw: data[:end]}, end, nil
+}
+
+// selfSig 主密钥的自签名中与有效期相关的信息
+type selfSig struct {
+	created time.Time
+	expiry  uint32 // 密钥有效期（秒），0 表示永不过期
+}
+
+// parsePackets 把数据包序列拆分为若干个公钥
+func parsePackets(data []byte) ([]*Key, error) {
+	var keys []*Key
+	var current *Key
+	// 最新的用户 ID 自签名和 direct-key 自签名，有效期优先取用户 ID 自签名
+	var latestCert, latestDirect *selfSig
+	inSubkey := false
+
+	finish := func() {
+		if current == nil {
+			return
+		}
+		var expiry uint32
+		if latestCert != nil {
+			expiry = latestCert.expiry
+		}
+		if expiry == 0 && latestDirect != nil {
+			expiry = latestDirect.expiry
+		}
+		if expiry > 0 {
+			current.Expires = current.Created.Add(time.Duration(expiry) * time.Second)
+		}
+		keys = append(keys, current)
+	}
+
+	for len(data) > 0 {
+		p, n, err := readPacket(data)
+		if err != nil {
+			return nil, err
+		}
+		data = data[n:]
+
+		switch p.tag {
+		case tagPublicKey:
+			finish()
+			current, latestCert, latestDirect, inSubkey = &Key{}, nil, nil, false
+			if err := current.parsePublicKey(p.body); err != nil {
+				return nil, err
+			}
+		case tagTrust:
+			// 本地信任信息，不导出
+			continue
+		case tagUserID:
+			if current == nil {
+				return nil, fmt.Errorf("user id packet before public key")
+			}
+			current.UserIDs = append(current.UserIDs, string(p.body))
+			inSubkey = false
+		case tagPublicSubkey:
+			if current == nil {
+				return nil, fmt.Errorf("subkey packet before public key")
+			}
+			current.Subkeys++
+			inSubkey = true
+		case tagSignature:
+			if current == nil {
+				return nil, fmt.Errorf("signature packet before public key")
+			}
+			if inSubkey {
+				break
+			}
+			sig, err := parseSignature(p.body)
+			if err != nil {
+				return nil, err
+			}
+			if !sig.issuedBy(current) {
+				break
+			}
+			self := &selfSig{created: sig.created, expiry: sig.keyExpiry}
+			switch {
+			case sig.sigType == sigKeyRevocation:
+				current.Revoked = true
+			case sig.sigType == sigDirectKey:
+				if latestDirect == nil || !sig.created.Before(latestDirect.created) {
+					latestDirect = self
+				}
+			case sig.sigType >= sigCertGeneric && sig.sigType <= sigCertPositive:
+				if latestCert == nil || !sig.created.Before(latestCert.created) {
+					latestCert = self
+				}
+			}
+		}
+		if current != nil {
+			current.packets = append(current.packets, p.raw...)
+		}
+	}
+	finish()
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no public key found")
+	}
+	return keys, nil
+}
+
+// parsePublicKey 解析 v4 公钥包，计算指纹和算法
+func (k *Key) parsePublicKey(body []byte) error {
+	if len(body) < 6 {
+		return fmt.Errorf("truncated public key packet")
+	}
+	if body[0] != 4 {
+		return fmt.Errorf("unsupported public key version %d", body[0])
+	}
+	k.Created = time.Unix(int64(binary.BigEndian.Uint32(body[1:5])), 0).UTC()
+	k.Algorithm = algorithmName(body[5], body[6:])
+
+	// v4 指纹：SHA1(0x99 || 两字节长度 || 公钥包内容)
+	h := sha1.New()
+	h.Write([]byte{0x99, byte(len(body) >> 8), byte(len(body))})
+	h.Write(body)
+	k.Fingerprint = strings.ToUpper(hex.EncodeToString(h.Sum(nil)))
+	k.KeyID = k.Fingerprint[24:]
+	return nil
+}
+
+// curveNames 常见椭圆曲线 OID
+var curveNames = map[string]string{
+	"2a8648ce3d030107":     "nistp256",
+	"2b81040022":           "nistp384",
+	"2b81040023":           "nistp521",
+	"2b06010401da470f01":   "ed25519",
+	"2b060104019755010501": "cv25519",
+	"2b2403030208010107":   "brainpoolP256r1",
+	"2b240303020801010b":   "brainpoolP384r1",
+	"2b240303020801010d":   "brainpoolP512r1",
+}
+
+// algorithmName 根据公钥算法和密钥材料返回 rsa4096、ed25519 之类的名称
+func algorithmName(algo byte, material []byte) string {
+	mpiBits := func(prefix string) string {
+		if len(material) < 2 {
+			return prefix
+		}
+		return fmt.Sprintf("%s%d", prefix, binary.BigEndian.Uint16(material[:2]))
+	}
+	curve := func(prefix string) string {
+		if len(material) < 1 || len(material) < 1+int(material[0]) {
+			return prefix
+		}
+		if name, ok := curveNames[hex.EncodeToString(material[1:1+int(material[0])])]; ok {
+			return name
+		}
+		return prefix
+	}
+
+	switch algo {
+	case 1, 2, 3:
+		return mpiBits("rsa")
+	case 16:
+		return mpiBits("elg")
+	case 17:
+		return mpiBits("dsa")
+	case 18:
+		return curve("ecdh")
+	case 19:
+		return curve("ecdsa")
+	case 22:
+		return curve("eddsa")
+	case 25:
+		return "x25519"
+	case 27:
+		return "ed25519"
+	}
+	return fmt.Sprintf("algo%d", algo)
+}
+
+// signature 签名包中与密钥管理相关的字段
+type signature struct {
+	sigType   byte
+	created   time.Time
+	keyExpiry uint32
+	issuer    string // 签名者的 Key ID 或指纹（大写十六进制）
+}
+
+// issuedBy 判断签名是否由指定密钥签发，未携带签发者信息时视为自签名
+func (s *signature) issuedBy(k *Key) bool {
+	return s.issuer == "" || strings.HasSuffix(k.Fingerprint, s.issuer)
+}
+
+// parseSignature 解析 v3 / v4 签名包
+func parseSignature(body []byte) (*signature, error) {
+	if len(body) < 1 {
+		return nil, fmt.Errorf("truncated signature packet")
+	}
+	sig := &signature{}
+
+	switch body[0] {
+	case 3:
+		if len(body) < 15 {
+			return nil, fmt.Errorf("truncated signature packet")
+		}
+		sig.sigType = body[2]
+		sig.created = time.Unix(int64(binary.BigEndian.Uint32(body[3:7])), 0).UTC()
+		sig.issuer = strings.ToUpper(hex.EncodeToString(body[7:15]))
+		return sig, nil
+	case 4:
+		if len(body) < 6 {
+			return nil, fmt.Errorf("truncated signature packet")
+		}
+		sig.sigType = body[1]
+		hashedLen := int(binary.BigEndian.Uint16(body[4:6]))
+		if len(body) < 6+hashedLen+2 {
+			return nil, fmt.Errorf("truncated signature packet")
+		}
+		if err := sig.parseSubpackets(body[6:6+hashedLen], true); err != nil {
+			return nil, err
+		}
+		rest := body[6+hashedLen:]
+		unhashedLen := int(binary.BigEndian.Uint16(rest[:2]))
+		if len(rest) < 2+unhashedLen {
+			return nil, fmt.Errorf("truncated signature packet")
+		}
+		if err := sig.parseSubpackets(rest[2:2+unhashedLen], false); err != nil {
+			return nil, err
+		}
+		return sig, nil
+	}
+
+	// 不认识的签名版本不影响密钥本身
+	return &signature{sigType: 0xff}, nil
+}
+
+// parseSubpackets 解析签名子包，有效期等信息只信任 hashed 区域
+func (s *signature) parseSubpackets(data []byte, hashed bool) error {
+	for len(data) > 0 {
+		var length, offset int
+		switch first := int(data[0]); {
+		case first < 192:
+			length, offset = first, 1
+		case first < 255:
+			if len(data) < 2 {
+				return fmt.Errorf("truncated signature subpacket")
+			}
+			length, offset = (first-192)<<8+int(data[1])+192, 2
+		default:
+			if len(data) < 5 {
+				return fmt.Errorf("truncated signature subpacket")
+			}
+			length, offset = int(binary.BigEndian.Uint32(data[1:5])), 5
+		}
+		if length < 1 || offset+length > len(data) {
+			return fmt.Errorf("truncated signature subpacket")
+		}
+		typ := data[offset] & 0x7f
+		value := data[offset+1 : offset+length]
+		data = data[offset+length:]
+
+		switch {
+		case typ == subpacketCreated && hashed && len(value) == 4:
+			s.created = time.Unix(int64(binary.BigEndian.Uint32(value)), 0).UTC()
+		case typ == subpacketKeyExpiry && hashed && len(value) == 4:
+			s.keyExpiry = binary.BigEndian.Uint32(value)
+		case typ == subpacketIssuer && len(value) == 8 && s.issuer == "":
+			s.issuer = strings.ToUpper(hex.EncodeToString(value))
+		case typ == subpacketIssuerFP && len(value) > 1:
+			s.issuer = strings.ToUpper(hex.EncodeToString(value[1:]))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package key
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testdata 中的密钥由 gpg 2.2 生成，期望值取自 gpg --with-colons --list-keys：
+// a.asc ed25519，2024-01-01 创建时有效期 1 年，2024-06-01 延长到 2026-06-01，两个自签名都保留在文件中；
+// b.asc rsa2048，永不过期；both.asc 是 gpg --armor --export 导出的两个密钥
+const (
+	fingerprintA = "65395131F71B9930BAA4D626CE2A7C324C93FB44"
+	fingerprintB = "432752FD50950A7F62E2531533230EB602B87835"
+)
+
+// readTestdata 读取 testdata 中的文件
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		file        string
+		fingerprint string
+		keyID       string
+		algorithm   string
+		created     int64
+		expires     int64 // 0 表示永不过期
+		userID      string
+		rpmName     string
+	}{
+		{
+			file:        "a.asc",
+			fingerprint: fingerprintA,
+			keyID:       "CE2A7C324C93FB44",
+			algorithm:   "ed25519",
+			created:     1704067200,
+			expires:     1780272000,
+			userID:      "Yuv Test A <a@example.com>",
+			rpmName:     "gpg-pubkey-4c93fb44-65920080",
+		},
+		{
+			file:        "b.asc",
+			fingerprint: fingerprintB,
+			keyID:       "33230EB602B87835",
+			algorithm:   "rsa2048",
+			created:     1706745600,
+			userID:      "Yuv Test B <b@example.com>",
+			rpmName:     "gpg-pubkey-02b87835-65badf00",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			keys, err := ParseKeys(readTestdata(t, tt.file))
+			if err != nil {
+				t.Fatalf("ParseKeys() error = %v", err)
+			}
+			if len(keys) != 1 {
+				t.Fatalf("ParseKeys() returned %d keys, want 1", len(keys))
+			}
+			k := keys[0]
+			if k.Fingerprint != tt.fingerprint || k.KeyID != tt.keyID {
+				t.Errorf("fingerprint, key id = %s, %s, want %s, %s", k.Fingerprint, k.KeyID, tt.fingerprint, tt.keyID)
+			}
+			if k.Algorithm != tt.algorithm {
+				t.Errorf("Algorithm = %s, want %s", k.Algorithm, tt.algorithm)
+			}
+			if k.Created.Unix() != tt.created {
+				t.Errorf("Created = %d, want %d", k.Created.Unix(), tt.created)
+			}
+			if tt.expires == 0 && !k.Expires.IsZero() {
+				t.Errorf("Expires = %v, want never", k.Expires)
+			}
+			if tt.expires != 0 && k.Expires.Unix() != tt.expires {
+				t.Errorf("Expires = %d, want %d", k.Expires.Unix(), tt.expires)
+			}
+			if k.UserID() != tt.userID {
+				t.Errorf("UserID() = %s, want %s", k.UserID(), tt.userID)
+			}
+			if k.RPMName() != tt.rpmName {
+				t.Errorf("RPMName() = %s, want %s", k.RPMName(), tt.rpmName)
+			}
+			if k.Revoked {
+				t.Errorf("Revoked = true, want false")
+			}
+		})
+	}
+}
+
+func TestParseKeysLatestSelfSignature(t *testing.T) {
+	keys, err := ParseKeys(readTestdata(t, "a.asc"))
+	if err != nil {
+		t.Fatalf("ParseKeys() error = %v", err)
+	}
+	k := keys[0]
+	// 第一个自签名的有效期到 2025-01-01，以 2024-06-01 的新自签名为准
+	if k.Expired(time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expired(2025-06-01) = true, want false")
+	}
+	if !k.Expired(time.Date(2026, 6, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expired(2026-06-02) = false, want true")
+	}
+
+	// 调换两个自签名的顺序，结果不变
+	var packets []*packet
+	for data := k.packets; len(data) > 0; {
+		p, n, err := readPacket(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		packets = append(packets, p)
+		data = data[n:]
+	}
+	var reordered []byte
+	for _, i := range []int{0, 1, 3, 2} {
+		reordered = append(reordered, packets[i].raw...)
+	}
+	keys, err = ParseKeys(reordered)
+	if err != nil {
+		t.Fatalf("ParseKeys() error = %v", err)
+	}
+	if keys[0].Expires.Unix() != 1780272000 {
+		t.Errorf("Expires = %d, want 1780272000", keys[0].Expires.Unix())
+	}
+}
+
+func TestParseKeysMultiple(t *testing.T) {
+	a, b := readTestdata(t, "a.asc"), readTestdata(t, "b.asc")
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"一个 armor 块中的多个密钥", readTestdata(t, "both.asc")},
+		{"多个 armor 块", append(append([]byte("# comment\n"), a...), b...)},
+		{"CRLF 换行", []byte(strings.ReplaceAll(string(a)+string(b), "\n", "\r\n"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := ParseKeys(tt.data)
+			if err != nil {
+				t.Fatalf("ParseKeys() error = %v", err)
+			}
+			var got []string
+			for _, k := range keys {
+				got = append(got, k.Fingerprint)
+			}
+			if strings.Join(got, ",") != fingerprintA+","+fingerprintB {
+				t.Errorf("ParseKeys() = %v, want %s, %s", got, fingerprintA, fingerprintB)
+			}
+			// 单独导出的密钥只包含自身的数据包
+			for _, k := range keys {
+				exported, err := ParseKeys(k.Armor())
+				if err != nil {
+					t.Fatalf("ParseKeys(Armor()) error = %v", err)
+				}
+				if len(exported) != 1 || exported[0].Fingerprint != k.Fingerprint || exported[0].Expires != k.Expires {
+					t.Errorf("Armor() of %s does not round trip", k.Fingerprint)
+				}
+			}
+		})
+	}
+}
+
+func TestParseKeysInvalid(t *testing.T) {
+	a := string(readTestdata(t, "a.asc"))
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"CRC24 不匹配", strings.Replace(a, "=LeYj", "=LeYk", 1), "checksum mismatch"},
+		{"数据被修改", strings.Replace(a, "mDMEZZIAgBYJ", "mDMEZZIAgBYK", 1), "checksum mismatch"},
+		{"缺少结束行", strings.Replace(a, armorEnd, "", 1), "missing end line"},
+		{"不是公钥", "hello", "invalid packet header"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseKeys([]byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseKeys() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCRC24(t *testing.T) {
+	// RFC 4880 6.1 的初始值，空数据的校验和就是初始值
+	if got := crc24(nil); got != 0xB704CE {
+		t.Errorf("crc24(nil) = %06X, want B704CE", got)
+	}
+	if got := crc24([]byte("123456789")); got != 0x21CF02 {
+		t.Errorf("crc24(123456789) = %06X, want 21CF02", got)
+	}
+}
+
+// fakeRPM 在 PATH 中放一个只记录参数的 rpm，公钥环为空
+func fakeRPM(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	log := filepath.Join(dir, "rpm.log")
+	script := "#!/bin/sh\necho \"$*\" >> " + log + "\n" +
+		"case \"$*\" in *gpg-pubkey*) echo \"package gpg-pubkey is not installed\"; exit 1;; esac\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "rpm"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return log
+}
+
+func TestImportPinned(t *testing.T) {
+	both := filepath.Join("testdata", "both.asc")
+	tests := []struct {
+		name         string
+		fingerprints []string
+		wantErr      string
+		wantImported []string
+	}{
+		{
+			name:    "未固定指纹",
+			wantErr: "no fingerprint pinned",
+		},
+		{
+			name:         "指纹不匹配",
+			fingerprints: []string{"0123 4567 89AB CDEF 0123  4567 89AB CDEF 0123 4567"},
+			wantErr:      "no key matches the pinned fingerprints",
+		},
+		{
+			name:         "固定的密钥已过期",
+			fingerprints: []string{fingerprintA},
+			wantErr:      "expired at 2026-06-01",
+		},
+		{
+			name:         "只导入固定的密钥",
+			fingerprints: []string{FormatFingerprint(fingerprintB)},
+			wantImported: []string{fingerprintB},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := fakeRPM(t)
+			keys, err := NewManager().Import(&Source{Name: "test", URL: both, Fingerprints: tt.fingerprints})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Import() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("Import() error = %v", err)
+			}
+
+			var imported []string
+			for _, k := range keys {
+				imported = append(imported, k.Fingerprint)
+			}
+			if strings.Join(imported, ",") != strings.Join(tt.wantImported, ",") {
+				t.Errorf("Import() = %v, want %v", imported, tt.wantImported)
+			}
+			calls, _ := ioutil.ReadFile(log)
+			if got := strings.Count(string(calls), "--import"); got != len(tt.wantImported) {
+				t.Errorf("rpm --import called %d times, want %d", got, len(tt.wantImported))
+			}
+		})
+	}
+}
@@ -7,7 +7,8 @@ import (
 
 // Manager 包管理器
 type Manager struct {
-	UseYum bool // 是否使用 yum 命令
+	UseYum      bool   // 是否使用 yum 命令
+	InstallRoot string // 备用根目录，通过 --installroot 传给 yum/dnf
 }
 
 // NewManager 创建包管理器实例
@@ -28,6 +29,14 @@ func (m *Manager) getCommand() string {
 	return "dnf"
 }
 
+// command 构建包管理命令，设置了 InstallRoot 时加上 --installroot
+func (m *Manager) command(args ...string) *exec.Cmd {
+	if m.InstallRoot != "" {
+		args = append([]string{"--installroot=" + m.InstallRoot}, args...)
+	}
+	return exec.Command(m.getCommand(), args...)
+}
+
 // Install 安装包
 func (m *Manager) Install(packages ...string) error {
 	// 检查是否已经包含 -y 标志
@@ -46,7 +55,7 @@ func (m *Manager) Install(packages ...string) error {
 	}
 	args = append(args, packages...)
 
-	cmd := exec.Command(m.getCommand(), args...)
+	cmd := m.command(args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
@@ -54,7 +63,7 @@ func (m *Manager) Install(packages ...string) error {
 
 // Remove 卸载包
 func (m *Manager) Remove(packages ...string) error {
-	cmd := exec.Command(m.getCommand(), append([]string{"remove", "-y"}, packages...)...)
+	cmd := m.command(append([]string{"remove", "-y"}, packages...)...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
@@ -66,7 +75,7 @@ func (m *Manager) Update(packages ...string) error {
 	if len(packages) > 0 {
 		args = append(args, packages...)
 	}
-	cmd := exec.Command(m.getCommand(), args...)
+	cmd := m.command(args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
@@ -74,7 +83,7 @@ func (m *Manager) Update(packages ...string) error {
 
 // Upgrade 升级系统
 func (m *Manager) Upgrade() error {
-	cmd := exec.Command(m.getCommand(), "upgrade", "-y")
+	cmd := m.command("upgrade", "-y")
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
@@ -82,7 +91,7 @@ func (m *Manager) Upgrade() error {
 
 // Erase 彻底卸载包
 func (m *Manager) Erase(packages ...string) error {
-	cmd := exec.Command(m.getCommand(), append([]string{"erase", "-y"}, packages...)...)
+	cmd := m.command(append([]string{"erase", "-y"}, packages...)...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
@@ -90,7 +99,7 @@ func (m *Manager) Erase(packages ...string) error {
 
 // Search 搜索包
 func (m *Manager) Search(pattern string) error {
-	cmd := exec.Command(m.getCommand(), "search", pattern)
+	cmd := m.command("search", pattern)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
@@ -98,7 +107,7 @@ func (m *Manager) Search(pattern string) error {
 
 // List 列出包
 func (m *Manager) List(args ...string) error {
-	cmd := exec.Command(m.getCommand(), append([]string{"list"}, args...)...)
+	cmd := m.command(append([]string{"list"}, args...)...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
@@ -106,7 +115,7 @@ func (m *Manager) List(args ...string) error {
 
 // Info 查看包信息
 func (m *Manager) Info(packages ...string) error {
-	cmd := exec.Command(m.getCommand(), append([]string{"info"}, packages...)...)
+	cmd := m.command(append([]string{"info"}, packages...)...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
@@ -114,7 +123,7 @@ func (m *Manager) Info(packages ...string) error {
 
 // Clean 清理缓存
 func (m *Manager) Clean() error {
-	cmd := exec.Command(m.getCommand(), "clean", "all")
+	cmd := m.command("clean", "all")
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
@@ -122,7 +131,7 @@ func (m *Manager) Clean() error {
 
 // MakeCache 生成缓存
 func (m *Manager) MakeCache() error {
-	cmd := exec.Command(m.getCommand(), "makecache")
+	cmd := m.command("makecache")
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
@@ -130,7 +139,7 @@ func (m *Manager) MakeCache() error {
 
 // Downgrade 降级包
 func (m *Manager) Downgrade(packageName string) error {
-	cmd := exec.Command(m.getCommand(), "downgrade", "-y", packageName)
+	cmd := m.command("downgrade", "-y", packageName)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
@@ -138,7 +147,7 @@ func (m *Manager) Downgrade(packageName string) error {
 
 // CheckUpdate 检查更新
 func (m *Manager) CheckUpdate() error {
-	cmd := exec.Command(m.getCommand(), "check-update")
+	cmd := m.command("check-update")
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
@@ -146,7 +155,7 @@ func (m *Manager) CheckUpdate() error {
 
 // Provides 查找提供指定文件的包
 func (m *Manager) Provides(filePath string) error {
-	cmd := exec.Command(m.getCommand(), "provides", filePath)
+	cmd := m.command("provides", filePath)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
@@ -154,7 +163,7 @@ func (m *Manager) Provides(filePath string) error {
 
 // WhatProvides 查找提供指定功能的包
 func (m *Manager) WhatProvides(feature string) error {
-	cmd := exec.Command(m.getCommand(), "whatprovides", feature)
+	cmd := m.command("whatprovides", feature)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
@@ -162,7 +171,7 @@ func (m *Manager) WhatProvides(feature string) error {
 
 // Deplist 查看包依赖
 func (m *Manager) Deplist(packageName string) error {
-	cmd := exec.Command(m.getCommand(), "deplist", packageName)
+	cmd := m.command("deplist", packageName)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
@@ -170,7 +179,7 @@ func (m *Manager) Deplist(packageName string) error {
 
 // History 查看历史
 func (m *Manager) History(args ...string) error {
-	cmd := exec.Command(m.getCommand(), append([]string{"history"}, args...)...)
+	cmd := m.command(append([]string{"history"}, args...)...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
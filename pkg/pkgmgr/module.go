@@ -0,0 +1,344 @@
+package pkgmgr
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ModuleStream dnf module list 中的一个模块流
+type ModuleStream struct {
+	Name      string   `json:"name"`
+	Stream    string   `json:"stream"`
+	Profiles  []string `json:"profiles,omitempty"`
+	Default   bool     `json:"default"`             // 默认流
+	Enabled   bool     `json:"enabled"`             // 已启用
+	Disabled  bool     `json:"disabled"`            // 已禁用
+	Installed []string `json:"installed,omitempty"` // 已安装的 profile
+	Summary   string   `json:"summary,omitempty"`
+	Repo      string   `json:"repo,omitempty"` // 提供该模块流的仓库名称
+}
+
+// ModuleInfo dnf module info 中的一个模块流版本
+type ModuleInfo struct {
+	Name            string   `json:"name"`
+	Stream          string   `json:"stream"`
+	Version         string   `json:"version,omitempty"`
+	Context         string   `json:"context,omitempty"`
+	Arch            string   `json:"arch,omitempty"`
+	Default         bool     `json:"default"`
+	Enabled         bool     `json:"enabled"`
+	Active          bool     `json:"active"`
+	Profiles        []string `json:"profiles,omitempty"`
+	DefaultProfiles []string `json:"default_profiles,omitempty"`
+	Installed       []string `json:"installed,omitempty"`
+	Repo            string   `json:"repo,omitempty"`
+	Summary         string   `json:"summary,omitempty"`
+	Description     string   `json:"description,omitempty"`
+	Requires        []string `json:"requires,omitempty"`
+	Artifacts       []string `json:"artifacts,omitempty"`
+}
+
+// moduleSpecPattern 模块规格 name 或 name:stream，可带 /profile
+var moduleSpecPattern = regexp.MustCompile(`^[A-Za-z0-9._+-]+(:[A-Za-z0-9._+-]+)?(/[A-Za-z0-9._+-]+)?$`)
+
+// moduleFlagPattern dnf 输出中的 [d]、[e]、[x]、[i]、[a] 标记
+var moduleFlagPattern = regexp.MustCompile(`\s*\[([a-z])\]`)
+
+// ValidateModuleSpec 校验模块规格 name[:stream][/profile]
+func ValidateModuleSpec(spec string) error {
+	if !moduleSpecPattern.MatchString(spec) {
+		return fmt.Errorf("invalid module spec %q, expected name[:stream][/profile]", spec)
+	}
+	return nil
+}
+
+// ModuleList 列出模块流，names 为空时列出所有模块
+func (m *Manager) ModuleList(names ...string) ([]*ModuleStream, error) {
+	out, err := m.moduleOutput(append([]string{"list"}, names...)...)
+	if err != nil {
+		return nil, err
+	}
+	return parseModuleList(out), nil
+}
+
+// ModuleInfo 查看模块流的详细信息，spec 为 name 或 name:stream
+func (m *Manager) ModuleInfo(spec string) ([]*ModuleInfo, error) {
+	if err := ValidateModuleSpec(spec); err != nil {
+		return nil, err
+	}
+	out, err := m.moduleOutput("info", spec)
+	if err != nil {
+		return nil, err
+	}
+	infos := parseModuleInfo(out)
+	if len(infos) == 0 {
+		return nil, fmt.Errorf("no matching module %s", spec)
+	}
+	return infos, nil
+}
+
+// ModuleEnable 启用模块流，未指定流时启用默认流
+func (m *Manager) ModuleEnable(specs ...string) error {
+	return m.moduleRun("enable", specs)
+}
+
+// ModuleDisable 禁用模块的所有流
+func (m *Manager) ModuleDisable(names ...string) error {
+	return m.moduleRun("disable", names)
+}
+
+// ModuleReset 把模块恢复到初始状态，既不启用也不禁用
+func (m *Manager) ModuleReset(names ...string) error {
+	return m.moduleRun("reset", names)
+}
+
+// ModuleSwitch 切换到模块的另一个流，并把已安装的软件包同步到新流的版本
+func (m *Manager) ModuleSwitch(spec string) error {
+	if !strings.Contains(spec, ":") {
+		return fmt.Errorf("module stream is required, expected name:stream")
+	}
+	return m.moduleRun("switch-to", []string{spec})
+}
+
+// checkModules 确认包管理器支持模块，yum 3（EL7）没有模块功能
+func (m *Manager) checkModules() error {
+	if m.UseYum {
+		return fmt.Errorf("module streams require dnf (EL8 or later)")
+	}
+	return nil
+}
+
+// moduleOutput 执行 dnf module 查询命令并返回标准输出，错误输出直接显示
+func (m *Manager) moduleOutput(args ...string) ([]byte, error) {
+	if err := m.checkModules(); err != nil {
+		return nil, err
+	}
+	var stdout bytes.Buffer
+	cmd := m.command(append([]string{"-q", "module"}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("dnf module %s failed: %v", args[0], err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// moduleRun 执行修改模块状态的 dnf module 命令
+func (m *Manager) moduleRun(action string, specs []string) error {
+	if err := m.checkModules(); err != nil {
+		return err
+	}
+	if len(specs) == 0 {
+		return fmt.Errorf("at least one module is required")
+	}
+	for _, spec := range specs {
+		if err := ValidateModuleSpec(spec); err != nil {
+			return err
+		}
+	}
+	cmd := m.command(append([]string{"module", "-y", action}, specs...)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("dnf module %s failed: %v", action, err)
+	}
+	return nil
+}
+
+// parseModuleList 解析 dnf module list 的表格输出：每个仓库一个表格，表头上一行为仓库名称，
+// 各列按表头的位置切分，流和 profile 后的 [d]、[e]、[x]、[i] 为状态标记；
+// 列宽不够时 Profiles 和 Summary 折行，折行的行前两列为空
+func parseModuleList(out []byte) []*ModuleStream {
+	var streams []*ModuleStream
+	var last *ModuleStream
+	var repo, prev string
+	var profilesAt, summaryAt int
+	inTable := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t")
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) >= 3 && fields[0] == "Name" && fields[1] == "Stream" && fields[2] == "Profiles":
+			repo = prev
+			profilesAt = strings.Index(line, "Profiles")
+			summaryAt = strings.Index(line, "Summary")
+			inTable = true
+			last = nil
+		case line == "" || strings.HasPrefix(line, "Hint:"):
+			inTable = false
+		case inTable && last != nil && (line[0] == ' ' || line[0] == '\t'):
+			appendModuleRow(last, line, profilesAt, summaryAt)
+		case inTable && len(fields) >= 2:
+			last = parseModuleRow(line, repo, profilesAt, summaryAt)
+			streams = append(streams, last)
+		}
+		if line != "" {
+			prev = line
+		}
+	}
+	return streams
+}
+
+// parseModuleRow 解析 dnf module list 表格中的一行
+func parseModuleRow(line, repo string, profilesAt, summaryAt int) *ModuleStream {
+	// 内容超出列宽时后面的列会右移，此时按空白重新定位
+	column := func(at int) int {
+		if at <= 0 || at > len(line) {
+			return len(line)
+		}
+		if line[at-1] != ' ' {
+			if next := strings.Index(line[at:], " "); next >= 0 {
+				return at + next + 1
+			}
+			return len(line)
+		}
+		return at
+	}
+	profilesStart := column(profilesAt)
+	summaryStart := len(line)
+	if summaryAt > 0 {
+		summaryStart = column(summaryAt)
+	}
+	if summaryStart < profilesStart {
+		summaryStart = profilesStart
+	}
+
+	head := strings.Fields(line[:profilesStart])
+	s := &ModuleStream{Name: head[0], Repo: repo, Summary: strings.TrimSpace(line[summaryStart:])}
+	stream, flags := splitModuleFlags(strings.Join(head[1:], " "))
+	s.Stream = stream
+	s.Default, s.Enabled, s.Disabled = flags["d"], flags["e"], flags["x"]
+
+	s.addProfiles(line[profilesStart:summaryStart])
+	return s
+}
+
+// appendModuleRow 把折行的 Profiles 和 Summary 追加到上一行的模块流
+func appendModuleRow(s *ModuleStream, line string, profilesAt, summaryAt int) {
+	cut := func(at int) int {
+		if at <= 0 || at > len(line) {
+			return len(line)
+		}
+		return at
+	}
+	profilesStart, summaryStart := cut(profilesAt), cut(summaryAt)
+	if summaryAt <= 0 || summaryStart < profilesStart {
+		summaryStart = len(line)
+	}
+	s.addProfiles(line[profilesStart:summaryStart])
+	if summary := strings.TrimSpace(line[summaryStart:]); summary != "" {
+		s.Summary = strings.TrimSpace(s.Summary + " " + summary)
+	}
+}
+
+// addProfiles 解析以逗号分隔的 profile 列表，带 [i] 标记的为已安装
+func (s *ModuleStream) addProfiles(text string) {
+	for _, item := range strings.Split(text, ",") {
+		profile, flags := splitModuleFlags(item)
+		if profile == "" {
+			continue
+		}
+		s.Profiles = append(s.Profiles, profile)
+		if flags["i"] {
+			s.Installed = append(s.Installed, profile)
+		}
+	}
+}
+
+// splitModuleFlags 去掉名称后面的 [d]、[e] 等标记，返回名称和标记集合
+func splitModuleFlags(s string) (string, map[string]bool) {
+	flags := map[string]bool{}
+	for _, match := range moduleFlagPattern.FindAllStringSubmatch(s, -1) {
+		flags[match[1]] = true
+	}
+	return strings.TrimSpace(moduleFlagPattern.ReplaceAllString(s, "")), flags
+}
+
+// parseModuleInfo 解析 dnf module info 的输出：每个版本一段，每行 "键 : 值"，
+// 键为空的行是上一个键的后续值
+func parseModuleInfo(out []byte) []*ModuleInfo {
+	var infos []*ModuleInfo
+	var info *ModuleInfo
+	var key string
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		k, value, ok := strings.Cut(line, " : ")
+		if !ok {
+			if k, value, ok = strings.Cut(line, " :"); !ok || strings.TrimSpace(value) != "" {
+				// 空行或提示信息结束当前段
+				info, key = nil, ""
+				continue
+			}
+		}
+		k, value = strings.TrimSpace(k), strings.TrimSpace(value)
+		if k != "" {
+			key = k
+		}
+		if key == "" {
+			continue
+		}
+		if info == nil {
+			info = &ModuleInfo{}
+			infos = append(infos, info)
+		}
+		applyModuleInfo(info, key, k == "", value)
+	}
+	return infos
+}
+
+// applyModuleInfo 把 dnf module info 中的一个值写入对应字段，cont 表示上一个键的后续行
+func applyModuleInfo(info *ModuleInfo, key string, cont bool, value string) {
+	switch key {
+	case "Name":
+		info.Name = value
+	case "Stream":
+		stream, flags := splitModuleFlags(value)
+		info.Stream = stream
+		info.Default, info.Enabled, info.Active = flags["d"], flags["e"], flags["a"]
+	case "Version":
+		info.Version = value
+	case "Context":
+		info.Context = value
+	case "Architecture":
+		info.Arch = value
+	case "Profiles":
+		for _, item := range strings.Split(value, ",") {
+			profile, flags := splitModuleFlags(item)
+			if profile == "" {
+				continue
+			}
+			info.Profiles = append(info.Profiles, profile)
+			if flags["i"] {
+				info.Installed = append(info.Installed, profile)
+			}
+		}
+	case "Default profiles":
+		info.DefaultProfiles = append(info.DefaultProfiles, strings.Fields(value)...)
+	case "Repo":
+		info.Repo = value
+	case "Summary":
+		info.Summary = value
+	case "Description":
+		if cont && info.Description != "" {
+			info.Description += " " + value
+		} else {
+			info.Description = value
+		}
+	case "Requires":
+		if value != "" {
+			info.Requires = append(info.Requires, value)
+		}
+	case "Artifacts":
+		if value != "" {
+			info.Artifacts = append(info.Artifacts, value)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package pkgmgr
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testdata 中的 dnf -q module list / info 输出按 Rocky Linux 8、9 上 dnf 4 的格式整理，
+// el8-module-list.txt 中 container-tools 的 Summary 和 nodejs 18 的 Profiles 折行
+
+// readTestdata 读取 testdata 中的文件
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// toJSON 便于在失败时比较结构体
+func toJSON(v interface{}) string {
+	data, _ := json.MarshalIndent(v, "", "  ")
+	return string(data)
+}
+
+func TestParseModuleList(t *testing.T) {
+	const appstream8 = "Rocky Linux 8 - AppStream"
+	const remi = "Remi's Modular repository for Enterprise Linux 8 - x86_64"
+	const appstream9 = "Rocky Linux 9 - AppStream"
+	tests := []struct {
+		file string
+		want []*ModuleStream
+	}{
+		{
+			file: "el8-module-list.txt",
+			want: []*ModuleStream{
+				{Name: "389-ds", Stream: "1.4", Summary: "389 Directory Server (base)", Repo: appstream8},
+				{
+					Name: "container-tools", Stream: "rhel8", Profiles: []string{"common"}, Default: true, Enabled: true,
+					Summary: "Most recent (rolling) versions of podman, buildah, skopeo, runc, conmon, " +
+						"CRIU, Udica, etc as well as dependencies such as container-selinux built " +
+						"and tested together, and updated as frequently as every 12 weeks.",
+					Repo: appstream8,
+				},
+				{Name: "mysql", Stream: "8.0", Profiles: []string{"client", "server"}, Default: true, Disabled: true, Summary: "MySQL Module", Repo: appstream8},
+				{Name: "nodejs", Stream: "10", Profiles: []string{"common", "development", "minimal", "s2i"}, Default: true, Summary: "Javascript runtime", Repo: appstream8},
+				{
+					Name: "nodejs", Stream: "18", Profiles: []string{"common", "development", "minimal", "s2i"}, Enabled: true,
+					Installed: []string{"common"}, Summary: "Javascript runtime", Repo: appstream8,
+				},
+				{Name: "perl-App-cpanminus", Stream: "1.7044", Profiles: []string{"common"}, Default: true, Summary: "Get, unpack, build and install CPAN modules", Repo: appstream8},
+				{Name: "postgresql", Stream: "10", Profiles: []string{"client", "server"}, Default: true, Summary: "PostgreSQL server and client module", Repo: appstream8},
+				{
+					Name: "php", Stream: "remi-8.2", Profiles: []string{"common", "devel", "minimal"}, Enabled: true,
+					Installed: []string{"common"}, Summary: "PHP scripting language", Repo: remi,
+				},
+				{Name: "postgresql-very-long-module-name", Stream: "13", Profiles: []string{"client", "server"}, Summary: "PostgreSQL server and client module", Repo: remi},
+			},
+		},
+		{
+			file: "el9-module-list.txt",
+			want: []*ModuleStream{
+				{Name: "maven", Stream: "3.8", Profiles: []string{"common"}, Summary: "Java project management and project comprehension tool", Repo: appstream9},
+				{Name: "nginx", Stream: "1.22", Profiles: []string{"common"}, Summary: "nginx webserver", Repo: appstream9},
+				{
+					Name: "nodejs", Stream: "18", Profiles: []string{"common", "development", "minimal", "s2i"}, Enabled: true,
+					Installed: []string{"common"}, Summary: "Javascript runtime", Repo: appstream9,
+				},
+				{Name: "php", Stream: "8.1", Profiles: []string{"common", "devel", "minimal"}, Summary: "PHP scripting language", Repo: appstream9},
+				{Name: "postgresql", Stream: "15", Profiles: []string{"client", "server"}, Summary: "PostgreSQL server and client module", Repo: appstream9},
+				{Name: "redis", Stream: "7", Profiles: []string{"common"}, Disabled: true, Summary: "Redis persistent key-value database", Repo: appstream9},
+			},
+		},
+		{
+			file: "el9-module-info.txt",
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got := parseModuleList(readTestdata(t, tt.file))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseModuleList() = %s\nwant %s", toJSON(got), toJSON(tt.want))
+			}
+		})
+	}
+}
+
+func TestParseModuleInfo(t *testing.T) {
+	nodejsArtifacts := []string{
+		"nodejs-1:18.18.2-1.module+el8.9.0+1500+9d1d2f7c.src",
+		"nodejs-1:18.18.2-1.module+el8.9.0+1500+9d1d2f7c.x86_64",
+		"npm-1:9.8.1-1.18.18.2.1.module+el8.9.0+1500+9d1d2f7c.x86_64",
+	}
+	tests := []struct {
+		file string
+		want []*ModuleInfo
+	}{
+		{
+			file: "el8-module-info.txt",
+			want: []*ModuleInfo{
+				{
+					Name: "nodejs", Stream: "18", Version: "8090020231019152822", Context: "a75119d5", Arch: "x86_64",
+					Enabled: true, Active: true,
+					Profiles:        []string{"common", "development", "minimal", "s2i"},
+					DefaultProfiles: []string{"common"},
+					Installed:       []string{"common"},
+					Repo:            "appstream",
+					Summary:         "Javascript runtime",
+					Description: "Node.js is a platform built on Chrome's JavaScript runtime for easily building fast, scalable network applications. " +
+						"Node.js uses an event-driven, non-blocking I/O model that makes it lightweight and efficient, perfect for " +
+						"data-intensive real-time applications that run across distributed devices.",
+					Requires:  []string{"platform:[el8]"},
+					Artifacts: nodejsArtifacts,
+				},
+				{
+					Name: "nodejs", Stream: "18", Version: "8080020230718104236", Context: "63b34585", Arch: "x86_64",
+					Enabled:         true,
+					Profiles:        []string{"common", "development", "minimal", "s2i"},
+					DefaultProfiles: []string{"common"},
+					Repo:            "appstream",
+					Summary:         "Javascript runtime",
+					Description:     "Node.js is a platform built on Chrome's JavaScript runtime for easily building fast, scalable network applications.",
+					Requires:        []string{"platform:[el8]"},
+					Artifacts:       []string{"nodejs-1:18.14.2-3.module+el8.8.0+1254+8bdd2f51.x86_64"},
+				},
+			},
+		},
+		{
+			file: "el9-module-info.txt",
+			want: []*ModuleInfo{
+				{
+					Name: "php", Stream: "8.1", Version: "9020020230511160151", Context: "rhel9", Arch: "x86_64",
+					Profiles:        []string{"common", "devel", "minimal"},
+					DefaultProfiles: []string{"common"},
+					Repo:            "appstream",
+					Summary:         "PHP scripting language",
+					Description:     "php 8.1 module",
+					Artifacts: []string{
+						"php-0:8.1.14-1.module+el9.2.0+14213+52f28e1c.src",
+						"php-cli-0:8.1.14-1.module+el9.2.0+14213+52f28e1c.x86_64",
+						"php-common-0:8.1.14-1.module+el9.2.0+14213+52f28e1c.x86_64",
+					},
+				},
+			},
+		},
+		{
+			file: "el9-module-list.txt",
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got := parseModuleInfo(readTestdata(t, tt.file))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseModuleInfo() = %s\nwant %s", toJSON(got), toJSON(tt.want))
+			}
+		})
+	}
+}
+
+func TestSplitModuleFlags(t *testing.T) {
+	tests := []struct {
+		input string
+		name  string
+		flags []string
+	}{
+		{"rhel8 [d][e]", "rhel8", []string{"d", "e"}},
+		{"8.0 [d][x]", "8.0", []string{"d", "x"}},
+		{" common [d] [i]", "common", []string{"d", "i"}},
+		{"18 [e] [a]", "18", []string{"a", "e"}},
+		{"minimal", "minimal", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			name, flags := splitModuleFlags(tt.input)
+			if name != tt.name {
+				t.Errorf("name = %q, want %q", name, tt.name)
+			}
+			if len(flags) != len(tt.flags) {
+				t.Errorf("flags = %v, want %v", flags, tt.flags)
+			}
+			for _, f := range tt.flags {
+				if !flags[f] {
+					t.Errorf("flag %s not set in %v", f, flags)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateModuleSpec(t *testing.T) {
+	tests := []struct {
+		spec  string
+		valid bool
+	}{
+		{"nodejs", true},
+		{"nodejs:18", true},
+		{"nodejs:18/common", true},
+		{"php:remi-8.2", true},
+		{"nodejs:18:extra", false},
+		{"nodejs 18", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			if err := ValidateModuleSpec(tt.spec); (err == nil) != tt.valid {
+				t.Errorf("ValidateModuleSpec(%q) error = %v, want valid %v", tt.spec, err, tt.valid)
+			}
+		})
+	}
+}
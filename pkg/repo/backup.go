@@ -0,0 +1,380 @@
+package repo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"yuv/internal/utils"
+)
+
+const (
+	// DefaultKeepBackups 默认保留的备份快照数量
+	DefaultKeepBackups = 10
+	// manifestFile 快照清单文件名
+	manifestFile = "manifest.json"
+	// legacySnapshotID 旧版本直接放在备份目录下的备份
+	legacySnapshotID = "legacy"
+	// snapshotIDFormat 快照 ID 的时间格式
+	snapshotIDFormat = "20060102-150405"
+)
+
+// Snapshot 源配置目录的一次备份快照
+type Snapshot struct {
+	ID      string         `json:"id"`
+	Created time.Time      `json:"created"`
+	Command string         `json:"command"`          // 触发备份的 yuv 命令
+	Pinned  bool           `json:"pinned,omitempty"` // 首个快照保存 yuv 修改前的原始源配置，不参与清理
+	Files   []SnapshotFile `json:"files"`
+	dir     string         // 快照所在目录
+}
+
+// SnapshotFile 快照中的单个文件
+type SnapshotFile struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+	Mode   string `json:"mode"` // 八进制权限，如 0644
+	Size   int64  `json:"size"`
+}
+
+// FileMode 解析文件权限
+func (f *SnapshotFile) FileMode() os.FileMode {
+	mode, err := strconv.ParseUint(f.Mode, 8, 32)
+	if err != nil {
+		return 0644
+	}
+	return os.FileMode(mode)
+}
+
+// Path 快照中文件的存放路径
+func (s *Snapshot) Path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+// File 根据文件名获取快照中的文件
+func (s *Snapshot) File(name string) *SnapshotFile {
+	for i := range s.Files {
+		if s.Files[i].Name == name {
+			return &s.Files[i]
+		}
+	}
+	return nil
+}
+
+// Backup 为源配置目录创建带时间戳的快照，并按保留策略清理旧快照；
+// 还没有任何快照时，新快照保存的是发行版原始的源配置，标记为固定快照
+func (m *Manager) Backup() (*Snapshot, error) {
+	// 创建备份目录
+	if err := os.MkdirAll(m.BackupDir, 0755); err != nil {
+		return nil, fmt.Errorf("create backup directory failed: %v", err)
+	}
+	existing, err := m.ListBackups()
+	if err != nil {
+		return nil, err
+	}
+
+	// 生成快照 ID，同一秒内多次备份时追加序号
+	now := time.Now()
+	id := now.Format(snapshotIDFormat)
+	for i := 1; ; i++ {
+		if _, err := os.Stat(filepath.Join(m.BackupDir, id)); os.IsNotExist(err) {
+			break
+		}
+		id = fmt.Sprintf("%s-%d", now.Format(snapshotIDFormat), i)
+	}
+
+	snapshot := &Snapshot{
+		ID:      id,
+		Created: now,
+		Command: m.Command,
+		Pinned:  len(existing) == 0,
+		dir:     filepath.Join(m.BackupDir, id),
+	}
+	if err := os.Mkdir(snapshot.dir, 0755); err != nil {
+		return nil, fmt.Errorf("create snapshot directory failed: %v", err)
+	}
+
+	// 复制源配置目录中的所有普通文件
+	files, err := ioutil.ReadDir(m.RepoDir)
+	if err != nil {
+		return nil, fmt.Errorf("read repo directory failed: %v", err)
+	}
+	for _, file := range files {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(m.RepoDir, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read repo file failed: %v", err)
+		}
+		if err := ioutil.WriteFile(snapshot.Path(file.Name()), content, file.Mode().Perm()); err != nil {
+			return nil, fmt.Errorf("write backup file failed: %v", err)
+		}
+		snapshot.Files = append(snapshot.Files, SnapshotFile{
+			Name:   file.Name(),
+			SHA256: sha256Hex(content),
+			Mode:   fmt.Sprintf("%04o", file.Mode().Perm()),
+			Size:   int64(len(content)),
+		})
+	}
+
+	// 清单最后写入，没有清单的目录不视为有效快照
+	manifest, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(snapshot.Path(manifestFile), manifest, 0644); err != nil {
+		return nil, fmt.Errorf("write manifest failed: %v", err)
+	}
+
+	if err := m.PruneBackups(m.KeepBackups); err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// ListBackups 列出所有快照，按时间从旧到新排序
+func (m *Manager) ListBackups() ([]*Snapshot, error) {
+	entries, err := ioutil.ReadDir(m.BackupDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read backup directory failed: %v", err)
+	}
+
+	var snapshots []*Snapshot
+	var legacy []os.FileInfo
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			if strings.HasSuffix(entry.Name(), ".repo") {
+				legacy = append(legacy, entry)
+			}
+			continue
+		}
+		dir := filepath.Join(m.BackupDir, entry.Name())
+		content, err := ioutil.ReadFile(filepath.Join(dir, manifestFile))
+		if err != nil {
+			continue
+		}
+		var snapshot Snapshot
+		if err := json.Unmarshal(content, &snapshot); err != nil {
+			return nil, fmt.Errorf("parse manifest of backup %s failed: %v", entry.Name(), err)
+		}
+		snapshot.dir = dir
+		snapshots = append(snapshots, &snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Created.Before(snapshots[j].Created)
+	})
+
+	// 旧版本直接移动到备份目录下的 .repo 文件，作为最早的快照
+	if len(legacy) > 0 {
+		snapshot := &Snapshot{ID: legacySnapshotID, dir: m.BackupDir}
+		for _, file := range legacy {
+			content, err := ioutil.ReadFile(filepath.Join(m.BackupDir, file.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("read backup file failed: %v", err)
+			}
+			if file.ModTime().After(snapshot.Created) {
+				snapshot.Created = file.ModTime()
+			}
+			snapshot.Files = append(snapshot.Files, SnapshotFile{
+				Name:   file.Name(),
+				SHA256: sha256Hex(content),
+				Mode:   fmt.Sprintf("%04o", file.Mode().Perm()),
+				Size:   int64(len(content)),
+			})
+		}
+		snapshots = append([]*Snapshot{snapshot}, snapshots...)
+	}
+
+	return snapshots, nil
+}
+
+// GetBackup 根据 ID（或唯一前缀）获取快照，ID 为空时返回最新的快照
+func (m *Manager) GetBackup(id string) (*Snapshot, error) {
+	snapshots, err := m.ListBackups()
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("no backup found")
+	}
+	if id == "" {
+		return snapshots[len(snapshots)-1], nil
+	}
+
+	var found []*Snapshot
+	for _, snapshot := range snapshots {
+		if snapshot.ID == id {
+			return snapshot, nil
+		}
+		if strings.HasPrefix(snapshot.ID, id) {
+			found = append(found, snapshot)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("backup %s not found", id)
+	case 1:
+		return found[0], nil
+	default:
+		return nil, fmt.Errorf("backup id %s is ambiguous", id)
+	}
+}
+
+// PruneBackups 只保留最新的 keep 个快照，keep <= 0 表示不清理，固定快照和旧版本备份不参与清理
+func (m *Manager) PruneBackups(keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	snapshots, err := m.ListBackups()
+	if err != nil {
+		return err
+	}
+
+	var managed []*Snapshot
+	for _, snapshot := range snapshots {
+		if snapshot.ID != legacySnapshotID && !snapshot.Pinned {
+			managed = append(managed, snapshot)
+		}
+	}
+	for len(managed) > keep {
+		if err := os.RemoveAll(managed[0].dir); err != nil {
+			return fmt.Errorf("remove backup %s failed: %v", managed[0].ID, err)
+		}
+		managed = managed[1:]
+	}
+	return nil
+}
+
+// DiffBackup 比较快照与当前源配置目录，返回统一 diff 格式的差异
+func (m *Manager) DiffBackup(id string) (string, error) {
+	snapshot, err := m.GetBackup(id)
+	if err != nil {
+		return "", err
+	}
+
+	names := map[string]bool{}
+	for _, file := range snapshot.Files {
+		names[file.Name] = true
+	}
+	current, err := ioutil.ReadDir(m.RepoDir)
+	if err != nil {
+		return "", fmt.Errorf("read repo directory failed: %v", err)
+	}
+	for _, file := range current {
+		if file.Mode().IsRegular() {
+			names[file.Name()] = true
+		}
+	}
+	var sorted []string
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var diff strings.Builder
+	for _, name := range sorted {
+		oldName, newName := "a/"+name, "b/"+name
+		var oldContent, newContent []byte
+		if snapshot.File(name) != nil {
+			oldContent, err = ioutil.ReadFile(snapshot.Path(name))
+			if err != nil {
+				return "", fmt.Errorf("read backup file failed: %v", err)
+			}
+		} else {
+			oldName = "/dev/null"
+		}
+		newContent, err = ioutil.ReadFile(filepath.Join(m.RepoDir, name))
+		if os.IsNotExist(err) {
+			newName = "/dev/null"
+		} else if err != nil {
+			return "", fmt.Errorf("read repo file failed: %v", err)
+		}
+		diff.WriteString(utils.UnifiedDiff(oldName, newName, oldContent, newContent))
+	}
+
+	return diff.String(), nil
+}
+
+// Restore 将源配置目录恢复到快照时的状态：删除之后新增的文件，
+// 还原被修改的文件，重建被删除的文件。id 为空时使用最新的快照，
+// 恢复前先为当前配置创建快照，DryRun 时只返回将要进行的变更
+func (m *Manager) Restore(id string) ([]*FileChange, error) {
+	snapshot, err := m.GetBackup(id)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := m.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+
+	// 读取并校验快照内容
+	for _, file := range snapshot.Files {
+		content, err := ioutil.ReadFile(snapshot.Path(file.Name))
+		if err != nil {
+			return nil, fmt.Errorf("read backup file failed: %v", err)
+		}
+		if snapshot.ID != legacySnapshotID && sha256Hex(content) != file.SHA256 {
+			return nil, fmt.Errorf("backup file %s is corrupted: checksum mismatch", file.Name)
+		}
+		if err := tx.RestoreFile(file.Name, content, file.FileMode()); err != nil {
+			return nil, err
+		}
+	}
+
+	// 删除快照之后新增的文件
+	current, err := ioutil.ReadDir(m.RepoDir)
+	if err != nil {
+		return nil, fmt.Errorf("read repo directory failed: %v", err)
+	}
+	for _, info := range current {
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		// 旧版本备份只包含 .repo 文件，不删除其他文件
+		if snapshot.ID == legacySnapshotID && !strings.HasSuffix(info.Name(), ".repo") {
+			continue
+		}
+		if snapshot.File(info.Name()) == nil {
+			tx.Remove(info.Name())
+		}
+	}
+
+	plan, err := tx.Plan()
+	if err != nil {
+		return nil, err
+	}
+	if len(plan.Changes) == 0 {
+		return nil, nil
+	}
+
+	// 恢复前为当前配置创建快照，恢复了错误的快照时可以再恢复回来
+	command := m.Command
+	m.Command = "restore " + snapshot.ID
+	defer func() { m.Command = command }()
+	if err := m.apply(tx, true); err != nil {
+		return nil, err
+	}
+	return plan.Changes, nil
+}
+
+// sha256Hex 计算内容的 SHA256
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
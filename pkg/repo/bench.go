@@ -0,0 +1,144 @@
+package repo
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"yuv/internal/utils"
+)
+
+// AutoMirror 自动选择最快镜像源时使用的名称
+const AutoMirror = "auto"
+
+// benchSampleLimit 测速时最多下载的样本字节数
+const benchSampleLimit = 2 << 20
+
+// BenchResult 单个镜像源的测速结果
+type BenchResult struct {
+	Name       string        // 镜像源名称
+	URL        string        // 探测的源地址
+	Latency    time.Duration // 获取 repomd.xml 的首字节延迟
+	Bytes      int64         // 样本文件下载的字节数
+	Throughput float64       // 样本文件下载速度（字节/秒）
+	Err        error         // 探测失败原因
+}
+
+// BenchMirror 探测单个源地址：获取 repodata/repomd.xml 测量延迟，
+// 再下载其中的 primary 元数据作为样本测量吞吐量
+func BenchMirror(client *utils.Client, name, baseURL string) *BenchResult {
+	result := &BenchResult{Name: name, URL: baseURL}
+
+	start := time.Now()
+	resp, err := client.Get(repomdURL(baseURL))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Latency = time.Since(start)
+	content, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		result.Err = fmt.Errorf("read repomd.xml failed: %v", err)
+		return result
+	}
+
+	md, err := ParseRepoMD(content)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	sample := md.Find("primary")
+	if sample == nil {
+		sample = &md.Data[0]
+	}
+
+	start = time.Now()
+	resp, err = client.Get(joinURL(baseURL, sample.Location.Href))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	n, err := io.Copy(ioutil.Discard, io.LimitReader(resp.Body, benchSampleLimit))
+	resp.Body.Close()
+	if err != nil {
+		result.Err = fmt.Errorf("download sample failed: %v", err)
+		return result
+	}
+	elapsed := time.Since(start)
+	result.Bytes = n
+	if elapsed > 0 {
+		result.Throughput = float64(n) / elapsed.Seconds()
+	}
+
+	return result
+}
+
+// RankBenchResults 对测速结果排序：成功的在前，吞吐量高的在前，吞吐量相同时延迟低的在前
+func RankBenchResults(results []*BenchResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if (a.Err == nil) != (b.Err == nil) {
+			return a.Err == nil
+		}
+		if a.Throughput != b.Throughput {
+			return a.Throughput > b.Throughput
+		}
+		return a.Latency < b.Latency
+	})
+}
+
+// Bench 对所有支持当前发行版的公共镜像源测速，返回排序后的结果
+func (m *Manager) Bench(releasever, basearch string) ([]*BenchResult, error) {
+	detector := m.detector()
+	distro, err := detector.GetDistroName()
+	if err != nil {
+		return nil, fmt.Errorf("get distro name failed: %v", err)
+	}
+	expired, err := detector.IsVersionExpired()
+	if err != nil {
+		return nil, fmt.Errorf("check version expired failed: %v", err)
+	}
+
+	mirrors, err := GetReposByType(TypePublic)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for name, repo := range mirrors {
+		if repo.Layout(distro, releasever) != nil {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no mirror supports %s", distro)
+	}
+	sort.Strings(names)
+
+	// 逐个探测，避免并发下载互相影响测速结果
+	var results []*BenchResult
+	for _, name := range names {
+		repo := mirrors[name]
+		component := repo.Components(distro, releasever)[0]
+		url := repo.ComponentURL(distro, releasever, basearch, component, expired)
+		results = append(results, BenchMirror(repo.Client(), name, url))
+	}
+	RankBenchResults(results)
+
+	return results, nil
+}
+
+// FastestMirror 测速并返回最快的可用镜像源名称
+func (m *Manager) FastestMirror(releasever, basearch string) (string, []*BenchResult, error) {
+	results, err := m.Bench(releasever, basearch)
+	if err != nil {
+		return "", nil, err
+	}
+	if results[0].Err != nil {
+		return "", results, fmt.Errorf("no mirror is reachable")
+	}
+	return results[0].Name, results, nil
+}
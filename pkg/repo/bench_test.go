@@ -0,0 +1,255 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"yuv/internal/utils"
+)
+
+// testMirror 测速用的本地镜像源
+type testMirror struct {
+	latency    time.Duration // repomd.xml 响应前的延迟
+	size       int           // 样本文件大小
+	chunks     int           // 样本文件分块发送的块数
+	chunkDelay time.Duration // 每块之间的延迟，用于限制吞吐量
+	types      []string      // repomd.xml 中的元数据类型，为空时只有 primary
+	repomd     string        // 非空时直接作为 repomd.xml 的内容
+
+	mu        sync.Mutex
+	requested []string // 请求过的路径
+}
+
+// start 启动镜像源，测试结束时关闭
+func (m *testMirror) start(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(m)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// ServeHTTP 提供 repodata/repomd.xml 和其中列出的元数据文件
+func (m *testMirror) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	m.requested = append(m.requested, r.URL.Path)
+	m.mu.Unlock()
+
+	types := m.types
+	if len(types) == 0 {
+		types = []string{"primary"}
+	}
+	if r.URL.Path == "/repodata/repomd.xml" {
+		time.Sleep(m.latency)
+		if m.repomd != "" {
+			fmt.Fprint(w, m.repomd)
+			return
+		}
+		var data strings.Builder
+		for _, t := range types {
+			fmt.Fprintf(&data, `<data type="%s"><location href="repodata/%s.xml.gz"/><size>%d</size></data>`, t, t, m.size)
+		}
+		fmt.Fprintf(w, `<?xml version="1.0"?><repomd><revision>1</revision>%s</repomd>`, data.String())
+		return
+	}
+	for _, t := range types {
+		if r.URL.Path == "/repodata/"+t+".xml.gz" {
+			m.writeSample(w)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+// writeSample 分块发送样本文件
+func (m *testMirror) writeSample(w http.ResponseWriter) {
+	chunks := m.chunks
+	if chunks <= 0 {
+		chunks = 1
+	}
+	w.Header().Set("Content-Length", fmt.Sprint(m.size))
+	sent := 0
+	for i := 0; i < chunks; i++ {
+		if i > 0 {
+			time.Sleep(m.chunkDelay)
+		}
+		n := m.size / chunks
+		if i == chunks-1 {
+			n = m.size - sent
+		}
+		w.Write([]byte(strings.Repeat("x", n)))
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		sent += n
+	}
+}
+
+// paths 返回请求过的路径
+func (m *testMirror) paths() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string{}, m.requested...)
+}
+
+// testClient 不使用代理的客户端，避免环境变量中的代理影响本地测试
+func testClient() *utils.Client {
+	return utils.NewClient(&utils.Proxy{})
+}
+
+func TestBenchMirror(t *testing.T) {
+	tests := []struct {
+		name      string
+		mirror    *testMirror
+		wantBytes int64
+		wantPath  string
+	}{
+		{
+			name:      "primary",
+			mirror:    &testMirror{latency: 50 * time.Millisecond, size: 64 << 10},
+			wantBytes: 64 << 10,
+			wantPath:  "/repodata/primary.xml.gz",
+		},
+		{
+			name:      "没有 primary 时使用第一条元数据",
+			mirror:    &testMirror{size: 1024, types: []string{"filelists", "other"}},
+			wantBytes: 1024,
+			wantPath:  "/repodata/filelists.xml.gz",
+		},
+		{
+			name:      "样本按上限截断",
+			mirror:    &testMirror{size: benchSampleLimit + 4096},
+			wantBytes: benchSampleLimit,
+			wantPath:  "/repodata/primary.xml.gz",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := tt.mirror.start(t)
+			result := BenchMirror(testClient(), "local", server.URL+"/")
+			if result.Err != nil {
+				t.Fatalf("BenchMirror() error = %v", result.Err)
+			}
+			if result.Name != "local" || result.URL != server.URL+"/" {
+				t.Errorf("BenchMirror() name, url = %s, %s", result.Name, result.URL)
+			}
+			if result.Latency < tt.mirror.latency {
+				t.Errorf("Latency = %v, want >= %v", result.Latency, tt.mirror.latency)
+			}
+			if result.Bytes != tt.wantBytes {
+				t.Errorf("Bytes = %d, want %d", result.Bytes, tt.wantBytes)
+			}
+			if result.Throughput <= 0 {
+				t.Errorf("Throughput = %v, want > 0", result.Throughput)
+			}
+			paths := tt.mirror.paths()
+			if len(paths) != 2 || paths[1] != tt.wantPath {
+				t.Errorf("requested %v, want sample %s", paths, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestBenchMirrorErrors(t *testing.T) {
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(notFound.Close)
+	invalid := (&testMirror{repomd: "not xml"}).start(t)
+	empty := (&testMirror{repomd: `<repomd><revision>1</revision></repomd>`}).start(t)
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{"repomd.xml 不存在", notFound.URL},
+		{"repomd.xml 无法解析", invalid.URL},
+		{"repomd.xml 没有元数据", empty.URL},
+		{"无法连接", closed.URL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := BenchMirror(testClient(), "local", tt.url); result.Err == nil {
+				t.Errorf("BenchMirror(%s) error = nil, want error", tt.url)
+			}
+		})
+	}
+}
+
+func TestBenchMirrorRanking(t *testing.T) {
+	// 吞吐量相差明显：fast 一次发送，slow 分 8 块、每块间隔 25ms
+	fast := (&testMirror{size: 256 << 10}).start(t)
+	slow := (&testMirror{size: 256 << 10, chunks: 8, chunkDelay: 25 * time.Millisecond}).start(t)
+	broken := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(broken.Close)
+
+	results := []*BenchResult{
+		BenchMirror(testClient(), "broken", broken.URL),
+		BenchMirror(testClient(), "slow", slow.URL),
+		BenchMirror(testClient(), "fast", fast.URL),
+	}
+	RankBenchResults(results)
+
+	var names []string
+	for _, r := range results {
+		names = append(names, r.Name)
+	}
+	if got := strings.Join(names, ","); got != "fast,slow,broken" {
+		t.Errorf("RankBenchResults() order = %s, want fast,slow,broken", got)
+	}
+}
+
+func TestRankBenchResults(t *testing.T) {
+	failed := errors.New("failed")
+	tests := []struct {
+		name    string
+		results []*BenchResult
+		want    string
+	}{
+		{
+			name: "吞吐量高的在前",
+			results: []*BenchResult{
+				{Name: "a", Throughput: 100, Latency: time.Millisecond},
+				{Name: "b", Throughput: 300, Latency: time.Second},
+				{Name: "c", Throughput: 200},
+			},
+			want: "b,c,a",
+		},
+		{
+			name: "吞吐量相同时延迟低的在前",
+			results: []*BenchResult{
+				{Name: "a", Throughput: 100, Latency: 30 * time.Millisecond},
+				{Name: "b", Throughput: 100, Latency: 10 * time.Millisecond},
+				{Name: "c", Throughput: 100, Latency: 20 * time.Millisecond},
+			},
+			want: "b,c,a",
+		},
+		{
+			name: "失败的排在最后并保持原有顺序",
+			results: []*BenchResult{
+				{Name: "x", Err: failed},
+				{Name: "a", Throughput: 1, Latency: time.Hour},
+				{Name: "y", Err: failed},
+				{Name: "b", Throughput: 2},
+			},
+			want: "b,a,x,y",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			RankBenchResults(tt.results)
+			var names []string
+			for _, r := range tt.results {
+				names = append(names, r.Name)
+			}
+			if got := strings.Join(names, ","); got != tt.want {
+				t.Errorf("RankBenchResults() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
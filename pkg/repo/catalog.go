@@ -0,0 +1,478 @@
+package repo
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"yuv/pkg/key"
+	"yuv/pkg/pkgmgr"
+)
+
+//go:embed catalog/*.yaml
+var builtinCatalog embed.FS
+
+// CatalogDirs 用户自定义源目录，按顺序加载，后加载的同名条目覆盖先加载的
+var CatalogDirs = []string{
+	"/etc/yuv/catalog.d",
+	"~/.config/yuv/catalog.d",
+}
+
+// SetCatalogRoot 为系统级源目录加上备用根目录前缀，用户目录（~ 开头）不变，需在首次加载源目录前调用
+func SetCatalogRoot(root string) {
+	for i, dir := range CatalogDirs {
+		if !strings.HasPrefix(dir, "~") {
+			CatalogDirs[i] = filepath.Join(root, dir)
+		}
+	}
+}
+
+// Catalog 合并后的源目录
+type Catalog struct {
+	Repos   map[string]*Repo  // 源名称 -> 源配置
+	Aliases map[string]string // 别名 -> 源名称
+	Sources map[string]string // 源名称 -> 定义该源的文件
+}
+
+// catalogFile 源目录文件的结构
+type catalogFile struct {
+	Repos   []*catalogEntry   `yaml:"repos"`
+	Aliases map[string]string `yaml:"aliases"`
+}
+
+// catalogEntry 源目录文件中的一个条目
+type catalogEntry struct {
+	Repo    `yaml:",inline"`
+	Enabled *bool       `yaml:"enabled"` // 未设置时默认启用
+	Mirror  *MirrorDirs `yaml:"mirror"`  // 按标准目录结构生成布局
+}
+
+// repoNamePattern 源名称允许的字符
+var repoNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// fingerprintPattern v4 密钥指纹
+var fingerprintPattern = regexp.MustCompile(`^[0-9A-F]{40}$`)
+
+var (
+	defaultCatalog     *Catalog
+	defaultCatalogErr  error
+	defaultCatalogOnce sync.Once
+)
+
+// DefaultCatalog 获取内置源目录与 CatalogDirs 合并后的源目录，只加载一次
+func DefaultCatalog() (*Catalog, error) {
+	defaultCatalogOnce.Do(func() {
+		defaultCatalog, defaultCatalogErr = LoadCatalog(CatalogDirs...)
+	})
+	return defaultCatalog, defaultCatalogErr
+}
+
+// LoadCatalog 加载内置源目录，再依次加载指定目录中的 *.yaml / *.yml 文件
+func LoadCatalog(dirs ...string) (*Catalog, error) {
+	c := &Catalog{
+		Repos:   map[string]*Repo{},
+		Aliases: map[string]string{},
+		Sources: map[string]string{},
+	}
+
+	// 内置源目录
+	builtin, err := builtinCatalog.ReadDir("catalog")
+	if err != nil {
+		return nil, fmt.Errorf("read builtin catalog failed: %v", err)
+	}
+	for _, file := range builtin {
+		name := "catalog/" + file.Name()
+		data, err := builtinCatalog.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("read builtin catalog failed: %v", err)
+		}
+		if err := c.load("builtin:"+name, data); err != nil {
+			return nil, err
+		}
+	}
+
+	// 用户源目录
+	for _, dir := range dirs {
+		dir = expandHome(dir)
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("read catalog directory failed: %v", err)
+		}
+		for _, file := range files {
+			ext := filepath.Ext(file.Name())
+			if file.IsDir() || (ext != ".yaml" && ext != ".yml") {
+				continue
+			}
+			path := filepath.Join(dir, file.Name())
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("read catalog file failed: %v", err)
+			}
+			if err := c.load(path, data); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// 所有文件合并后再检查别名，别名可以指向源的某个版本（如 mysql@8.0）
+	for alias, target := range c.Aliases {
+		name, version := SplitVersion(target)
+		repo, ok := c.Repos[name]
+		if !ok {
+			return nil, fmt.Errorf("catalog: alias %s refers to unknown repo %s", alias, name)
+		}
+		if _, err := repo.WithVersion(version); err != nil {
+			return nil, fmt.Errorf("catalog: alias %s: %v", alias, err)
+		}
+	}
+
+	return c, nil
+}
+
+// load 解析并校验一个源目录文件，合并到当前目录
+func (c *Catalog) load(source string, data []byte) error {
+	var file catalogFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && err != io.EOF {
+		if typeErr, ok := err.(*yaml.TypeError); ok {
+			return fmt.Errorf("catalog %s: %s", source, strings.Join(typeErr.Errors, "; "))
+		}
+		return fmt.Errorf("catalog %s: %v", source, err)
+	}
+
+	seen := map[string]bool{}
+	for i, entry := range file.Repos {
+		if entry == nil {
+			return fmt.Errorf("catalog %s: repos[%d]: empty entry", source, i)
+		}
+		repo := entry.toRepo()
+		if err := validateRepo(repo); err != nil {
+			return fmt.Errorf("catalog %s: repos[%d] (%s): %v", source, i, repo.Name, err)
+		}
+		if seen[repo.Name] {
+			return fmt.Errorf("catalog %s: repos[%d]: duplicate repo %s", source, i, repo.Name)
+		}
+		seen[repo.Name] = true
+
+		c.Repos[repo.Name] = repo
+		c.Sources[repo.Name] = source
+	}
+
+	for alias, target := range file.Aliases {
+		if !repoNamePattern.MatchString(alias) {
+			return fmt.Errorf("catalog %s: invalid alias %q", source, alias)
+		}
+		c.Aliases[alias] = target
+	}
+
+	return nil
+}
+
+// toRepo 将目录条目转换为源配置，补全默认值
+func (e *catalogEntry) toRepo() *Repo {
+	repo := e.Repo
+	repo.Enabled = e.Enabled == nil || *e.Enabled
+	repo.Fingerprints = nil
+	for _, fp := range e.Fingerprints {
+		repo.Fingerprints = append(repo.Fingerprints, key.NormalizeFingerprint(fp))
+	}
+	if e.Mirror != nil {
+		layouts := e.Mirror.StandardLayouts()
+		// 显式配置的布局优先，同时覆盖按主版本号区分的标准布局（如 rockylinux 覆盖 rockylinux8）
+		for key := range repo.Layouts {
+			for standard := range layouts {
+				if standard != key && strings.TrimRight(standard, "0123456789") == key {
+					delete(layouts, standard)
+				}
+			}
+		}
+		for key, layout := range repo.Layouts {
+			layouts[key] = layout
+		}
+		repo.Layouts = layouts
+	}
+	return &repo
+}
+
+// validateRepo 校验源配置
+func validateRepo(r *Repo) error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if !repoNamePattern.MatchString(r.Name) {
+		return fmt.Errorf("invalid name %q: only letters, digits, '.', '_' and '-' are allowed", r.Name)
+	}
+	switch r.Type {
+	case TypePublic:
+		if len(r.Layouts) == 0 {
+			return fmt.Errorf("public repo requires mirror or layouts")
+		}
+	case TypeThird, TypeCustom:
+		// Copr 项目和远程 .repo 文件不需要地址模板
+		if r.URL == "" && len(r.BaseURLs) == 0 && r.Mirrorlist == "" && r.Metalink == "" && r.Copr == "" && r.RepoFile == "" {
+			return fmt.Errorf("url, baseurls, mirrorlist, metalink, repo_file or copr is required")
+		}
+	case "":
+		return fmt.Errorf("type is required")
+	default:
+		return fmt.Errorf("unknown type %q, expected public, third or custom", r.Type)
+	}
+	if r.Priority < 0 || r.Priority > 99 {
+		return fmt.Errorf("priority %d out of range 1-99, use 0 to leave it unset", r.Priority)
+	}
+
+	for _, fp := range r.Fingerprints {
+		if !fingerprintPattern.MatchString(fp) {
+			return fmt.Errorf("invalid fingerprint %q: expected 40 hex digits", fp)
+		}
+	}
+	if len(r.Fingerprints) > 0 && r.GPGKey == "" {
+		return fmt.Errorf("fingerprints requires gpgkey")
+	}
+
+	if r.Mirrorlist != "" && r.Metalink != "" {
+		return fmt.Errorf("mirrorlist and metalink are mutually exclusive")
+	}
+	if r.Proxy != nil {
+		if err := r.Proxy.Validate(); err != nil {
+			return fmt.Errorf("proxy: %v", err)
+		}
+	}
+	if err := validateInstaller(r); err != nil {
+		return err
+	}
+	seenVersions := map[string]bool{}
+	for i, v := range r.Versions {
+		if v == nil || !versionPattern.MatchString(v.Version) {
+			return fmt.Errorf("versions[%d]: invalid version", i)
+		}
+		if seenVersions[v.Version] {
+			return fmt.Errorf("versions[%d]: duplicate version %s", i, v.Version)
+		}
+		seenVersions[v.Version] = true
+	}
+	if r.DefaultVersion != "" && !seenVersions[r.DefaultVersion] {
+		return fmt.Errorf("default_version %s is not in versions", r.DefaultVersion)
+	}
+	if r.Modules != nil {
+		specs := [][]string{r.Modules.Reset, r.Modules.Disable, r.Modules.Enable}
+		for j, key := range []string{"reset", "disable", "enable"} {
+			for i, spec := range specs[j] {
+				if err := pkgmgr.ValidateModuleSpec(spec); err != nil {
+					return fmt.Errorf("modules.%s[%d]: %v", key, i, err)
+				}
+			}
+		}
+	}
+
+	urls := map[string]string{"url": r.URL, "mirrorlist": r.Mirrorlist, "metalink": r.Metalink, "vault_url": r.VaultURL, "gpgkey": r.GPGKey, "release_rpm": r.ReleaseRPM, "repo_file": r.RepoFile}
+	for i, u := range r.BaseURLs {
+		urls[fmt.Sprintf("baseurls[%d]", i)] = u
+	}
+	for i, v := range r.Versions {
+		prefix := fmt.Sprintf("versions[%d].", i)
+		urls[prefix+"url"] = v.URL
+		urls[prefix+"gpgkey"] = v.GPGKey
+		urls[prefix+"release_rpm"] = v.ReleaseRPM
+	}
+	for key, layout := range r.Layouts {
+		if layout == nil {
+			return fmt.Errorf("layouts.%s: empty layout", key)
+		}
+		if layout.URL == "" && len(layout.BaseURLs) == 0 && layout.Mirrorlist == "" && layout.Metalink == "" {
+			return fmt.Errorf("layouts.%s: url, baseurls, mirrorlist or metalink is required", key)
+		}
+		if layout.Mirrorlist != "" && layout.Metalink != "" {
+			return fmt.Errorf("layouts.%s: mirrorlist and metalink are mutually exclusive", key)
+		}
+		if r.Type == TypePublic && len(layout.Components) == 0 {
+			return fmt.Errorf("layouts.%s: components is required", key)
+		}
+		prefix := "layouts." + key + "."
+		urls[prefix+"url"] = layout.URL
+		urls[prefix+"mirrorlist"] = layout.Mirrorlist
+		urls[prefix+"metalink"] = layout.Metalink
+		urls[prefix+"vault_url"] = layout.VaultURL
+		urls[prefix+"gpgkey"] = layout.GPGKey
+		urls[prefix+"release_rpm"] = layout.ReleaseRPM
+		urls[prefix+"repo_file"] = layout.RepoFile
+		for i, u := range layout.BaseURLs {
+			urls[fmt.Sprintf("%sbaseurls[%d]", prefix, i)] = u
+		}
+	}
+	for key, value := range urls {
+		if err := validateURL(value); err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+	}
+
+	return nil
+}
+
+// validateInstaller 校验安装方式及其需要的配置
+func validateInstaller(r *Repo) error {
+	if r.Copr != "" {
+		if err := ValidateCoprProject(r.Copr); err != nil {
+			return fmt.Errorf("copr: %v", err)
+		}
+	}
+
+	// release_rpm、repo_file 可以只在部分发行版的布局中配置
+	has := func(field func(l *Layout) string, value string) bool {
+		if value != "" {
+			return true
+		}
+		for _, layout := range r.Layouts {
+			if layout != nil && field(layout) != "" {
+				return true
+			}
+		}
+		return false
+	}
+
+	// 通过 release RPM 安装的源必须声明 release_package，删除源时卸载该包
+	usesRPM := r.Installer == InstallReleaseRPM || has(func(l *Layout) string { return l.ReleaseRPM }, r.ReleaseRPM)
+	for _, v := range r.Versions {
+		usesRPM = usesRPM || (v != nil && v.ReleaseRPM != "")
+	}
+	if usesRPM && r.ReleasePackage == "" {
+		if len(r.Versions) == 0 {
+			return fmt.Errorf("release_rpm requires release_package")
+		}
+		for i, v := range r.Versions {
+			if v != nil && v.ReleasePackage == "" {
+				return fmt.Errorf("versions[%d]: release_rpm requires release_package", i)
+			}
+		}
+	}
+
+	if r.Installer == "" {
+		return nil
+	}
+	switch r.Installer {
+	case InstallTemplate:
+		return nil
+	case InstallReleaseRPM:
+		if !has(func(l *Layout) string { return l.ReleaseRPM }, r.ReleaseRPM) {
+			return fmt.Errorf("installer release_rpm requires release_rpm")
+		}
+		return nil
+	case InstallRepoFile:
+		if !has(func(l *Layout) string { return l.RepoFile }, r.RepoFile) {
+			return fmt.Errorf("installer repo_file requires repo_file")
+		}
+		return nil
+	case InstallCopr:
+		if r.Copr == "" {
+			return fmt.Errorf("installer copr requires copr")
+		}
+		return nil
+	}
+	var methods []string
+	for _, method := range InstallMethods {
+		methods = append(methods, string(method))
+	}
+	return fmt.Errorf("unknown installer %q, expected %s", r.Installer, strings.Join(methods, ", "))
+}
+
+// validateURL 校验地址模板，只允许 http、https、ftp 和 file 协议
+func validateURL(value string) error {
+	if value == "" {
+		return nil
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("invalid url %q: %v", value, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "ftp", "file":
+		return nil
+	}
+	return fmt.Errorf("invalid url %q: unsupported scheme %q", value, u.Scheme)
+}
+
+// Get 根据名称或别名获取源配置，支持 name@version 指定版本，声明了版本的源未指定时使用默认版本
+func (c *Catalog) Get(name string) (*Repo, error) {
+	repo, version, err := c.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	return repo.WithVersion(version)
+}
+
+// resolve 解析名称、别名和 @version，返回源目录中未替换版本变量的源配置和指定的版本；
+// 别名指向某个版本时，显式指定的版本优先
+func (c *Catalog) resolve(spec string) (*Repo, string, error) {
+	name, version := SplitVersion(spec)
+	if target, ok := c.Aliases[name]; ok {
+		var aliasVersion string
+		name, aliasVersion = SplitVersion(target)
+		if version == "" {
+			version = aliasVersion
+		}
+	}
+	if repo, ok := c.Repos[name]; ok {
+		return repo, version, nil
+	}
+	return nil, "", fmt.Errorf("repo %s not found", name)
+}
+
+// AliasesOf 返回指向指定源（或其某个版本）的别名，按名称排序
+func (c *Catalog) AliasesOf(name string) []string {
+	var aliases []string
+	for alias, target := range c.Aliases {
+		if base, _ := SplitVersion(target); base == name {
+			aliases = append(aliases, alias)
+		}
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
+// ByType 获取指定类型的所有源
+func (c *Catalog) ByType(repoType RepoType) map[string]*Repo {
+	repos := map[string]*Repo{}
+	for name, repo := range c.Repos {
+		if repo.Type == repoType {
+			repos[name] = repo
+		}
+	}
+	return repos
+}
+
+// Names 按名称排序返回所有源
+func (c *Catalog) Names() []string {
+	var names []string
+	for name := range c.Repos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// expandHome 展开路径开头的 ~
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
@@ -0,0 +1,32 @@
+package repo
+
+import (
+	"os"
+	"sort"
+)
+
+// ChangeAction 文件变更类型
+type ChangeAction string
+
+const (
+	ChangeCreate ChangeAction = "create" // 新建文件
+	ChangeModify ChangeAction = "modify" // 修改内容
+	ChangeDelete ChangeAction = "delete" // 删除文件
+	ChangeMode   ChangeAction = "mode"   // 只修改权限
+)
+
+// FileChange 源配置目录中的一个文件变更
+type FileChange struct {
+	Action  ChangeAction `json:"action"`
+	Name    string       `json:"name"`               // 相对于源配置目录的文件名，源配置目录之外的文件为绝对路径
+	Mode    os.FileMode  `json:"mode,omitempty"`     // 变更后的权限
+	OldMode os.FileMode  `json:"old_mode,omitempty"` // 变更前的权限
+	Diff    string       `json:"diff,omitempty"`     // 内容变更的统一 diff
+}
+
+// sortChanges 按文件名排序
+func sortChanges(changes []*FileChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+}
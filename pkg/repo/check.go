@@ -0,0 +1,317 @@
+package repo
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"yuv/internal/utils"
+)
+
+// mirrorlistLimit mirrorlist / metalink 最多下载的字节数
+const mirrorlistLimit = 1 << 20
+
+// CheckResult 单个源的有效性检查结果
+type CheckResult struct {
+	ID       string   `json:"id"`
+	File     string   `json:"file"`
+	URL      string   `json:"url,omitempty"`      // 检查通过的源地址
+	Revision string   `json:"revision,omitempty"` // repomd.xml 中的版本号
+	OK       bool     `json:"ok"`
+	Problems []string `json:"problems,omitempty"` // 失败原因
+}
+
+// problemf 记录一条失败原因
+func (r *CheckResult) problemf(format string, args ...interface{}) {
+	r.Problems = append(r.Problems, fmt.Sprintf(format, args...))
+}
+
+// Check 检查源的有效性，未指定时检查所有已启用的源
+func (m *Manager) Check(patterns ...string) ([]*CheckResult, error) {
+	var matches []*SectionMatch
+	if len(patterns) == 0 {
+		files, err := m.loadRepoFiles()
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			for _, section := range file.File.Sections {
+				if section.Enabled() {
+					matches = append(matches, &SectionMatch{Path: file.Path, File: file.File, Section: section})
+				}
+			}
+		}
+	} else {
+		for _, pattern := range patterns {
+			found, err := m.FindSections(pattern)
+			if err != nil {
+				return nil, err
+			}
+			matches = append(matches, found...)
+		}
+	}
+
+	expand, err := m.expander()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*CheckResult
+	for _, match := range matches {
+		result := CheckSection(match.Section, expand)
+		result.File = match.Path
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// expander 返回按当前系统替换 yum 变量的函数，与 dnf 实际请求的地址一致
+func (m *Manager) expander() (func(string) string, error) {
+	vars, err := m.Vars()
+	if err != nil {
+		return nil, err
+	}
+	return func(s string) string {
+		s = vars.Expand(s)
+		// 备用根目录中的本地文件，如 file:///etc/pki/rpm-gpg/ 下的密钥
+		if m.Root != "" && strings.HasPrefix(s, "file://") {
+			s = "file://" + filepath.Join(m.Root, strings.TrimPrefix(s, "file://"))
+		}
+		return s
+	}, nil
+}
+
+// CheckSection 检查一个源：解析 baseurl / mirrorlist / metalink，
+// 下载并解析 repomd.xml，校验 primary 元数据的校验和，并确认 gpgkey 可以下载
+func CheckSection(section *Section, expand func(string) string) *CheckResult {
+	result := &CheckResult{ID: section.ID}
+	client := sectionClient(section, expand)
+
+	candidates, err := resolveBaseURLs(client, section, expand)
+	if err != nil {
+		result.problemf("%v", err)
+	}
+	for _, baseURL := range candidates {
+		revision, err := checkMetadata(client, baseURL)
+		if err != nil {
+			result.problemf("%s: %v", baseURL, err)
+			continue
+		}
+		// 任意一个地址可用即可，清除之前地址的失败原因
+		result.URL = baseURL
+		result.Revision = revision
+		result.Problems = nil
+		break
+	}
+	if result.URL == "" && len(result.Problems) == 0 {
+		result.problemf("no usable baseurl, mirrorlist or metalink")
+	}
+
+	// GPG 密钥
+	gpgcheck, err := section.Bool("gpgcheck", false)
+	if err != nil {
+		result.problemf("gpgcheck: %v", err)
+	}
+	keys := section.Values("gpgkey")
+	if gpgcheck && len(keys) == 0 {
+		result.problemf("gpgcheck is enabled but no gpgkey is configured")
+	}
+	for _, key := range keys {
+		key = expand(key)
+		content, err := client.Fetch(key)
+		if err != nil {
+			result.problemf("gpgkey %s: %v", key, err)
+			continue
+		}
+		if !looksLikePGPKey(content) {
+			result.problemf("gpgkey %s: not an OpenPGP public key", key)
+		}
+	}
+
+	result.OK = len(result.Problems) == 0
+	return result
+}
+
+// ValidateSection 确认源至少有一个地址可以下载并解析 repo 元数据，只下载 repomd.xml，比 CheckSection 快
+func ValidateSection(section *Section, expand func(string) string) error {
+	client := sectionClient(section, expand)
+	candidates, err := resolveBaseURLs(client, section, expand)
+	if err != nil {
+		return err
+	}
+	if len(candidates) == 0 {
+		return fmt.Errorf("no usable baseurl, mirrorlist or metalink")
+	}
+
+	var problems []string
+	for _, baseURL := range candidates {
+		content, err := client.Fetch(repomdURL(baseURL))
+		if err == nil {
+			_, err = ParseRepoMD(content)
+		}
+		if err == nil {
+			return nil
+		}
+		problems = append(problems, fmt.Sprintf("%s: %v", baseURL, err))
+	}
+	return fmt.Errorf("%s", strings.Join(problems, "; "))
+}
+
+// looksLikePGPKey 粗略判断内容是否为 OpenPGP 公钥（ASCII armor 或二进制包）
+func looksLikePGPKey(content []byte) bool {
+	if strings.Contains(string(content), "-----BEGIN PGP PUBLIC KEY BLOCK-----") {
+		return true
+	}
+	// 二进制格式首字节为包头，最高位必须为 1
+	return len(content) > 0 && content[0]&0x80 != 0
+}
+
+// resolveBaseURLs 获取源的候选地址：先是所有 baseurl，再是 metalink 或 mirrorlist 中的镜像，与 dnf 的尝试顺序一致。
+// 有 baseurl 时 mirrorlist / metalink 下载失败不视为错误
+func resolveBaseURLs(client *utils.Client, section *Section, expand func(string) string) ([]string, error) {
+	var urls []string
+	for _, u := range section.Values("baseurl") {
+		urls = append(urls, expand(u))
+	}
+
+	var problem error
+	// 同时配置时 dnf 只使用 metalink
+	if metalink := section.Value("metalink"); metalink != "" {
+		metalink = expand(metalink)
+		content, err := client.FetchLimit(metalink, mirrorlistLimit)
+		if err != nil {
+			problem = fmt.Errorf("metalink %s: %v", metalink, err)
+		} else if mirrors, err := parseMetalink(content); err != nil {
+			problem = fmt.Errorf("metalink %s: %v", metalink, err)
+		} else {
+			urls = append(urls, mirrors...)
+		}
+	} else if mirrorlist := section.Value("mirrorlist"); mirrorlist != "" {
+		mirrorlist = expand(mirrorlist)
+		content, err := client.FetchLimit(mirrorlist, mirrorlistLimit)
+		if err != nil {
+			problem = fmt.Errorf("mirrorlist %s: %v", mirrorlist, err)
+		} else if mirrors := parseMirrorlist(content); len(mirrors) == 0 {
+			problem = fmt.Errorf("mirrorlist %s: no mirrors", mirrorlist)
+		} else {
+			urls = append(urls, mirrors...)
+		}
+	}
+
+	if len(urls) == 0 && problem != nil {
+		return nil, problem
+	}
+	return urls, nil
+}
+
+// parseMirrorlist 解析 mirrorlist 内容，每行一个地址，忽略注释
+func parseMirrorlist(content []byte) []string {
+	var urls []string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	return urls
+}
+
+// metalinkDoc metalink 文件中与 repomd.xml 相关的部分
+type metalinkDoc struct {
+	Files []struct {
+		Name string `xml:"name,attr"`
+		URLs []struct {
+			Protocol string `xml:"protocol,attr"`
+			Value    string `xml:",chardata"`
+		} `xml:"resources>url"`
+	} `xml:"files>file"`
+}
+
+// parseMetalink 解析 metalink，返回 repomd.xml 所在源的地址列表
+func parseMetalink(content []byte) ([]string, error) {
+	var doc metalinkDoc
+	if err := xml.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("parse metalink failed: %v", err)
+	}
+
+	var urls []string
+	for _, file := range doc.Files {
+		if file.Name != "repomd.xml" {
+			continue
+		}
+		for _, u := range file.URLs {
+			if u.Protocol != "http" && u.Protocol != "https" {
+				continue
+			}
+			urls = append(urls, strings.TrimSuffix(strings.TrimSpace(u.Value), "repodata/repomd.xml"))
+		}
+	}
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("no http mirrors for repomd.xml")
+	}
+	return urls, nil
+}
+
+// checkMetadata 下载并解析 repomd.xml，校验 primary 元数据，返回 repomd 的版本号
+func checkMetadata(client *utils.Client, baseURL string) (string, error) {
+	content, err := client.Fetch(repomdURL(baseURL))
+	if err != nil {
+		return "", err
+	}
+	md, err := ParseRepoMD(content)
+	if err != nil {
+		return "", err
+	}
+
+	primary := md.Find("primary")
+	if primary == nil {
+		return "", fmt.Errorf("repomd.xml has no primary metadata")
+	}
+	if err := verifyChecksum(client, joinURL(baseURL, primary.Location.Href), primary.Checksum.Type, primary.Checksum.Value); err != nil {
+		return "", fmt.Errorf("primary metadata: %v", err)
+	}
+
+	return md.Revision, nil
+}
+
+// verifyChecksum 下载文件并校验 repomd.xml 中记录的校验和
+func verifyChecksum(client *utils.Client, url, checksumType, expected string) error {
+	var h hash.Hash
+	switch strings.ToLower(checksumType) {
+	case "sha", "sha1":
+		h = sha1.New()
+	case "sha224":
+		h = sha256.New224()
+	case "sha256":
+		h = sha256.New()
+	case "sha384":
+		h = sha512.New384()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return fmt.Errorf("unsupported checksum type %q", checksumType)
+	}
+
+	body, err := client.Open(url)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if _, err := io.Copy(h, body); err != nil {
+		return fmt.Errorf("download failed: %v", err)
+	}
+
+	actual := hex.EncodeToString(h.Sum(nil))
+	if !strings.EqualFold(actual, strings.TrimSpace(expected)) {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", strings.TrimSpace(expected), actual)
+	}
+	return nil
+}
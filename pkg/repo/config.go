@@ -1,8 +1,9 @@
 package repo
 
 import (
-	"fmt"
 	"strings"
+
+	"yuv/internal/utils"
 )
 
 // RepoType 源类型
@@ -16,177 +17,326 @@ const (
 
 // Repo 源配置结构体
 type Repo struct {
-	Name       string   // 源名称
-	Type       RepoType // 源类型
-	URL        string   // 源URL
-	VaultURL   string   // 过期源URL
-	GPGKey     string   // GPG密钥URL
-	Enabled    bool     // 是否启用
-	Priority   int      // 优先级
-	Releasever string   // 发行版版本变量
-	Basearch   string   // 架构变量
-}
-
-// PublicRepos 预置公共镜像源
-var PublicRepos = map[string]*Repo{
-	"aliyun": {
-		Name:     "aliyun",
-		Type:     TypePublic,
-		URL:      "https://mirrors.aliyun.com/$distro/$releasever/AppStream/$basearch/os/",
-		VaultURL: "https://mirrors.aliyun.com/$distro-vault/$releasever/AppStream/$basearch/os/",
-		GPGKey:   "https://mirrors.aliyun.com/$distro/RPM-GPG-KEY-$distro-$releasever",
-		Enabled:  true,
-		Priority: 1,
-	},
-}
-
-// ThirdRepos 预置第三方官方源
-var ThirdRepos = map[string]*Repo{
-	"mysql57": {
-		Name:     "mysql57",
-		Type:     TypeThird,
-		URL:      "https://repo.mysql.com/yum/mysql-5.7-community/el/$releasever/$basearch/",
-		GPGKey:   "https://repo.mysql.com/RPM-GPG-KEY-mysql",
-		Enabled:  true,
-		Priority: 5,
-	},
-	"redis": {
-		Name:     "redis",
-		Type:     TypeThird,
-		URL:      "https://rpms.remirepo.net/enterprise/$releasever/redis/$basearch/",
-		GPGKey:   "https://rpms.remirepo.net/RPM-GPG-KEY-remi",
-		Enabled:  true,
-		Priority: 5,
-	},
-	"nginx": {
-		Name:     "nginx",
-		Type:     TypeThird,
-		URL:      "https://nginx.org/packages/centos/$releasever/$basearch/",
-		GPGKey:   "https://nginx.org/keys/nginx_signing.key",
-		Enabled:  true,
-		Priority: 5,
-	},
-	"docker": {
-		Name:     "docker",
-		Type:     TypeThird,
-		URL:      "https://download.docker.com/linux/centos/$releasever/$basearch/stable",
-		GPGKey:   "https://download.docker.com/linux/centos/gpg",
-		Enabled:  true,
-		Priority: 5,
-	},
-	"k8s": {
-		Name:     "k8s",
-		Type:     TypeThird,
-		URL:      "https://packages.cloud.google.com/yum/repos/kubernetes-el7-$basearch",
-		GPGKey:   "https://packages.cloud.google.com/yum/doc/yum-key.gpg",
-		Enabled:  true,
-		Priority: 5,
-	},
-	"php7": {
-		Name:     "php7",
-		Type:     TypeThird,
-		URL:      "https://rpms.remirepo.net/enterprise/$releasever/php74/$basearch/",
-		GPGKey:   "https://rpms.remirepo.net/RPM-GPG-KEY-remi",
-		Enabled:  true,
-		Priority: 5,
-	},
-	"php8": {
-		Name:     "php8",
-		Type:     TypeThird,
-		URL:      "https://rpms.remirepo.net/enterprise/$releasever/php80/$basearch/",
-		GPGKey:   "https://rpms.remirepo.net/RPM-GPG-KEY-remi",
-		Enabled:  true,
-		Priority: 5,
-	},
-	"nodejs": {
-		Name:     "nodejs",
-		Type:     TypeThird,
-		URL:      "https://rpm.nodesource.com/pub_16.x/el/$releasever/$basearch/",
-		GPGKey:   "https://rpm.nodesource.com/pub/el/NODESOURCE-GPG-SIGNING-KEY-EL",
-		Enabled:  true,
-		Priority: 5,
-	},
-}
-
-// GetRepoByName 根据名称获取源配置
-func GetRepoByName(name string) (*Repo, error) {
-	// 处理 kubernetes 别名
-	if name == "kubernetes" {
-		name = "k8s"
+	Name           string             `yaml:"name"`            // 源名称
+	Type           RepoType           `yaml:"type"`            // 源类型
+	URL            string             `yaml:"url"`             // 源URL
+	BaseURLs       []string           `yaml:"baseurls"`        // 备用源URL，按顺序排在 URL 之后
+	Mirrorlist     string             `yaml:"mirrorlist"`      // mirrorlist 地址
+	Metalink       string             `yaml:"metalink"`        // metalink 地址
+	VaultURL       string             `yaml:"vault_url"`       // 过期源URL
+	GPGKey         string             `yaml:"gpgkey"`          // GPG密钥URL
+	Enabled        bool               `yaml:"-"`               // 是否启用
+	Priority       int                `yaml:"priority"`        // 优先级 1-99，0 表示不设置
+	Releasever     string             `yaml:"-"`               // 发行版版本变量
+	Basearch       string             `yaml:"-"`               // 架构变量
+	Layouts        map[string]*Layout `yaml:"layouts"`         // 公共镜像源按发行版区分的目录布局
+	Fingerprints   []string           `yaml:"fingerprints"`    // GPG密钥的固定指纹，只导入匹配的密钥
+	Proxy          *utils.Proxy       `yaml:"proxy"`           // 访问该源使用的代理，url 为空表示不使用代理
+	Modules        *ModuleActions     `yaml:"modules"`         // 添加源时调整的 dnf 模块流
+	ReleaseRPM     string             `yaml:"release_rpm"`     // 提供官方 .repo 文件和密钥的 release RPM，配置后添加源时安装该 RPM
+	ReleasePackage string             `yaml:"release_package"` // release RPM 的包名，删除源时卸载，配置 release_rpm 时必填
+	RepoFile       string             `yaml:"repo_file"`       // 远程 .repo 文件地址，配置后添加源时下载该文件
+	Copr           string             `yaml:"copr"`            // Copr 项目 owner/project
+	Installer      InstallMethod      `yaml:"installer"`       // 安装方式，为空时根据 copr、release_rpm、repo_file 推断
+	NeedsCRB       bool               `yaml:"needs_crb"`       // 依赖 CRB（EL9）/ PowerTools（EL8）仓库，添加时自动启用
+	Versions       []*RepoVersion     `yaml:"versions"`        // 支持的版本，地址模板中的 $version 替换为选中的版本
+	DefaultVersion string             `yaml:"default_version"` // 未指定版本时使用的版本，为空时使用最后一个版本
+	Version        string             `yaml:"-"`               // 选中的版本
+}
+
+// ModuleActions 添加源时需要调整的 dnf 模块流，按 reset、disable、enable 的顺序执行，只在 EL8 及以后的系统上执行
+type ModuleActions struct {
+	Reset   []string `yaml:"reset"`   // 恢复初始状态的模块
+	Disable []string `yaml:"disable"` // 禁用的模块，避免模块过滤屏蔽该源中的同名软件包
+	Enable  []string `yaml:"enable"`  // 启用的模块流 name:stream
+}
+
+// Layout 公共镜像源针对某个发行版的目录布局
+// 地址模板中 $component 表示子仓库（BaseOS、AppStream、extras 等）
+type Layout struct {
+	URL        string   `yaml:"url"`         // 源地址模板
+	BaseURLs   []string `yaml:"baseurls"`    // 备用源地址模板，按顺序排在 url 之后
+	Mirrorlist string   `yaml:"mirrorlist"`  // mirrorlist 地址模板
+	Metalink   string   `yaml:"metalink"`    // metalink 地址模板
+	VaultURL   string   `yaml:"vault_url"`   // 过期版本的源地址模板
+	GPGKey     string   `yaml:"gpgkey"`      // GPG密钥URL
+	ReleaseRPM string   `yaml:"release_rpm"` // 第三方源在该发行版上使用的 release RPM，为空时按地址模板生成 .repo 文件
+	RepoFile   string   `yaml:"repo_file"`   // 第三方源在该发行版上使用的远程 .repo 文件
+	Components []string `yaml:"components"`  // 需要生成的子仓库
+	Optional   []string `yaml:"optional"`    // 生成但默认禁用的子仓库，如 CRB、PowerTools
+}
+
+// MirrorDirs 公共镜像源中各发行版所在的目录，以 http 开头时为完整地址
+type MirrorDirs struct {
+	Host         string `yaml:"host"`
+	CentOS       string `yaml:"centos"`
+	CentOSVault  string `yaml:"centos_vault"`
+	CentOSStream string `yaml:"centos_stream"`
+	Rocky        string `yaml:"rocky"`
+	RockyVault   string `yaml:"rocky_vault"`
+	Alma         string `yaml:"alma"`
+	AlmaVault    string `yaml:"alma_vault"`
+}
+
+// StandardLayouts 按照标准目录结构生成公共镜像源的布局，覆盖 CentOS 7/8 vault、CentOS Stream、Rocky 和 Alma
+// 未配置目录的发行版不生成布局
+func (dirs *MirrorDirs) StandardLayouts() map[string]*Layout {
+	dir := func(d string) string {
+		if strings.HasPrefix(d, "http://") || strings.HasPrefix(d, "https://") {
+			return strings.TrimRight(d, "/")
+		}
+		return strings.TrimRight(dirs.Host, "/") + "/" + d
 	}
-	// 处理 mysql8 别名
-	if name == "mysql8" {
-		name = "mysql57"
+	el := "$releasever/$component/$basearch/os/"
+	// Rocky 和 Alma 的镜像站只保留当前小版本，使用主版本号目录
+	elMajor := "$releasever_major/$component/$basearch/os/"
+
+	layouts := map[string]*Layout{}
+	if dirs.CentOS != "" || dirs.CentOSVault != "" {
+		layouts["centos7"] = &Layout{
+			URL:        dir(dirs.CentOS) + "/$releasever/$component/$basearch/",
+			VaultURL:   dir(dirs.CentOSVault) + "/$releasever/$component/$basearch/",
+			GPGKey:     "file:///etc/pki/rpm-gpg/RPM-GPG-KEY-CentOS-7",
+			Components: []string{"os", "updates", "extras"},
+		}
+		layouts["centos8"] = &Layout{
+			URL:        dir(dirs.CentOS) + "/" + el,
+			VaultURL:   dir(dirs.CentOSVault) + "/" + el,
+			GPGKey:     "file:///etc/pki/rpm-gpg/RPM-GPG-KEY-centosofficial",
+			Components: []string{"BaseOS", "AppStream", "extras"},
+			Optional:   []string{"PowerTools"},
+		}
+	}
+	if dirs.CentOSStream != "" {
+		layouts["centos-stream"] = &Layout{
+			URL:        dir(dirs.CentOSStream) + "/$releasever_major-stream/$component/$basearch/os/",
+			VaultURL:   dir(dirs.CentOSVault) + "/$releasever_major-stream/$component/$basearch/os/",
+			GPGKey:     "file:///etc/pki/rpm-gpg/RPM-GPG-KEY-centosofficial",
+			Components: []string{"BaseOS", "AppStream"},
+			Optional:   []string{"CRB"},
+		}
+		layouts["centos-stream8"] = withOptional(layouts["centos-stream"], "PowerTools")
 	}
-	// 先查找公共源
-	if repo, ok := PublicRepos[name]; ok {
-		return repo, nil
+	if dirs.Rocky != "" {
+		layouts["rockylinux"] = &Layout{
+			URL:        dir(dirs.Rocky) + "/" + elMajor,
+			VaultURL:   dir(dirs.RockyVault) + "/" + el,
+			GPGKey:     "file:///etc/pki/rpm-gpg/RPM-GPG-KEY-Rocky-$releasever_major",
+			Components: []string{"BaseOS", "AppStream", "extras"},
+			Optional:   []string{"CRB"},
+		}
+		layouts["rockylinux8"] = withOptional(layouts["rockylinux"], "PowerTools")
 	}
-	// 再查找第三方源
-	if repo, ok := ThirdRepos[name]; ok {
-		return repo, nil
+	if dirs.Alma != "" {
+		layouts["almalinux"] = &Layout{
+			URL:        dir(dirs.Alma) + "/" + elMajor,
+			VaultURL:   dir(dirs.AlmaVault) + "/" + el,
+			GPGKey:     "file:///etc/pki/rpm-gpg/RPM-GPG-KEY-AlmaLinux-$releasever_major",
+			Components: []string{"BaseOS", "AppStream", "extras"},
+			Optional:   []string{"CRB"},
+		}
+		layouts["almalinux8"] = &Layout{
+			URL:        dir(dirs.Alma) + "/" + elMajor,
+			VaultURL:   dir(dirs.AlmaVault) + "/" + el,
+			GPGKey:     "file:///etc/pki/rpm-gpg/RPM-GPG-KEY-AlmaLinux",
+			Components: []string{"BaseOS", "AppStream", "extras"},
+			Optional:   []string{"PowerTools"},
+		}
+	}
+	return layouts
+}
+
+// withOptional 复制布局并替换默认禁用的子仓库，用于 EL8 的 PowerTools 与 EL9 的 CRB 目录名不同的情况
+func withOptional(layout *Layout, optional ...string) *Layout {
+	copied := *layout
+	copied.Optional = optional
+	return &copied
+}
+
+// GetRepoByName 根据名称获取源配置，从合并后的源目录中查找
+func GetRepoByName(name string) (*Repo, error) {
+	catalog, err := DefaultCatalog()
+	if err != nil {
+		return nil, err
 	}
-	return nil, fmt.Errorf("repo %s not found", name)
+	return catalog.Get(name)
 }
 
 // GetReposByType 根据类型获取源列表
-func GetReposByType(repoType RepoType) map[string]*Repo {
-	switch repoType {
-	case TypePublic:
-		return PublicRepos
-	case TypeThird:
-		return ThirdRepos
-	default:
-		return nil
+func GetReposByType(repoType RepoType) (map[string]*Repo, error) {
+	catalog, err := DefaultCatalog()
+	if err != nil {
+		return nil, err
 	}
+	return catalog.ByType(repoType), nil
 }
 
 // ReplaceVariables 替换源URL中的变量
 func (r *Repo) ReplaceVariables(distro, releasever, basearch string) string {
-	url := r.URL
-	url = strings.ReplaceAll(url, "$distro", distro)
-	
-	// 对 MySQL 仓库特殊处理，只使用主版本号
-	if strings.Contains(r.Name, "mysql") {
-		// 提取主版本号（如从 8.9 提取 8）
-		parts := strings.Split(releasever, ".")
-		if len(parts) > 0 {
-			releasever = parts[0]
-		}
-	}
-	
-	url = strings.ReplaceAll(url, "$releasever", releasever)
-	url = strings.ReplaceAll(url, "$basearch", basearch)
-	return url
+	return expandVars(r.URL, distro, releasever, basearch)
 }
 
 // GetVaultURL 获取过期源URL（替换变量）
 func (r *Repo) GetVaultURL(distro, releasever, basearch string) string {
-	url := r.VaultURL
-	url = strings.ReplaceAll(url, "$distro", distro)
-	
-	// 对 MySQL 仓库特殊处理，只使用主版本号
-	if strings.Contains(r.Name, "mysql") {
-		// 提取主版本号（如从 8.9 提取 8）
-		parts := strings.Split(releasever, ".")
-		if len(parts) > 0 {
-			releasever = parts[0]
+	return expandVars(r.VaultURL, distro, releasever, basearch)
+}
+
+// GetGPGKeyURL 获取GPG密钥URL（替换变量），公共镜像源使用对应发行版布局中的密钥
+func (r *Repo) GetGPGKeyURL(distro, releasever, basearch string) string {
+	gpgKey := r.GPGKey
+	if layout := r.Layout(distro, releasever); layout != nil {
+		gpgKey = layout.GPGKey
+	}
+	if gpgKey == "" {
+		return ""
+	}
+	return expandVars(gpgKey, distro, releasever, basearch)
+}
+
+// layoutKeys 返回查找目录布局时依次尝试的键
+// CentOS 区分 7、8 与 Stream（版本号不带小版本），其他发行版先按主版本号再按名称查找；
+// EL 系发行版最后尝试 el<主版本号>，供只区分主版本号的第三方源使用
+func layoutKeys(distro, releasever string) []string {
+	parts := strings.Split(releasever, ".")
+	major := parts[0]
+	if distro == "centos" {
+		if major == "7" {
+			return []string{"centos7", "el7"}
+		}
+		if len(parts) == 1 {
+			return []string{"centos-stream" + major, "centos-stream", "el" + major}
 		}
+		return []string{"centos" + major, "el" + major}
 	}
-	
-	url = strings.ReplaceAll(url, "$releasever", releasever)
-	url = strings.ReplaceAll(url, "$basearch", basearch)
-	return url
+	if distro == "fedora" {
+		return []string{distro + major, distro}
+	}
+	return []string{distro + major, distro, "el" + major}
 }
 
-// GetGPGKeyURL 获取GPG密钥URL（替换变量）
-func (r *Repo) GetGPGKeyURL(distro, releasever, basearch string) string {
-	if r.GPGKey == "" {
+// Layout 获取指定发行版的目录布局，没有布局时返回 nil
+func (r *Repo) Layout(distro, releasever string) *Layout {
+	for _, key := range layoutKeys(distro, releasever) {
+		if layout, ok := r.Layouts[key]; ok {
+			return layout
+		}
+	}
+	return nil
+}
+
+// Components 获取需要生成的子仓库列表
+func (r *Repo) Components(distro, releasever string) []string {
+	if layout := r.Layout(distro, releasever); layout != nil {
+		return layout.Components
+	}
+	return []string{"BaseOS", "AppStream"}
+}
+
+// OptionalComponents 获取生成但默认禁用的子仓库列表
+func (r *Repo) OptionalComponents(distro, releasever string) []string {
+	if layout := r.Layout(distro, releasever); layout != nil {
+		return layout.Optional
+	}
+	return nil
+}
+
+// ComponentURL 获取指定子仓库（BaseOS、AppStream 等）的第一个 baseurl（替换变量），只有 mirrorlist / metalink 时返回空字符串
+// 过期版本优先使用 VaultURL
+func (r *Repo) ComponentURL(distro, releasever, basearch, component string, expired bool) string {
+	urls := r.ComponentURLs(distro, releasever, basearch, component, expired)
+	if len(urls.BaseURLs) == 0 {
 		return ""
 	}
-	gpgKey := r.GPGKey
-	gpgKey = strings.ReplaceAll(gpgKey, "$distro", distro)
-	gpgKey = strings.ReplaceAll(gpgKey, "$releasever", releasever)
-	gpgKey = strings.ReplaceAll(gpgKey, "$basearch", basearch)
-	return gpgKey
+	return urls.BaseURLs[0]
+}
+
+// ComponentURLs 获取指定子仓库的所有地址（替换变量），有发行版布局时使用布局中的地址模板
+func (r *Repo) ComponentURLs(distro, releasever, basearch, component string, expired bool) *RepoURLs {
+	expand := func(s string) string {
+		return expandVars(strings.ReplaceAll(s, "$component", component), distro, releasever, basearch)
+	}
+	if layout := r.Layout(distro, releasever); layout != nil {
+		return resolveURLs(layout.URL, layout.BaseURLs, layout.Mirrorlist, layout.Metalink, layout.VaultURL, expired, expand)
+	}
+	return resolveURLs(r.URL, r.BaseURLs, r.Mirrorlist, r.Metalink, r.VaultURL, expired, expand)
+}
+
+// URLs 获取源的所有地址（替换变量），有发行版布局时使用布局中的地址模板，过期版本优先使用 VaultURL
+func (r *Repo) URLs(distro, releasever, basearch string, expired bool) *RepoURLs {
+	expand := func(s string) string {
+		return expandVars(s, distro, releasever, basearch)
+	}
+	if layout := r.Layout(distro, releasever); layout != nil {
+		return resolveURLs(layout.URL, layout.BaseURLs, layout.Mirrorlist, layout.Metalink, layout.VaultURL, expired, expand)
+	}
+	return resolveURLs(r.URL, r.BaseURLs, r.Mirrorlist, r.Metalink, r.VaultURL, expired, expand)
+}
+
+// ReleaseRPMURL 获取 release RPM 地址（替换变量），有发行版布局时只使用布局中的配置，未配置时返回空字符串
+func (r *Repo) ReleaseRPMURL(distro, releasever, basearch string) string {
+	rpm := r.ReleaseRPM
+	if layout := r.Layout(distro, releasever); layout != nil {
+		rpm = layout.ReleaseRPM
+	}
+	if rpm == "" {
+		return ""
+	}
+	return expandVars(rpm, distro, releasever, basearch)
+}
+
+// RepoFileURL 获取远程 .repo 文件地址（替换变量），有发行版布局时只使用布局中的配置，未配置时返回空字符串
+func (r *Repo) RepoFileURL(distro, releasever, basearch string) string {
+	repoFile := r.RepoFile
+	if layout := r.Layout(distro, releasever); layout != nil {
+		repoFile = layout.RepoFile
+	}
+	if repoFile == "" {
+		return ""
+	}
+	return expandVars(repoFile, distro, releasever, basearch)
+}
+
+// RepoURLs 写入 .repo 文件的地址，已替换变量
+type RepoURLs struct {
+	BaseURLs   []string // 按顺序尝试的 baseurl
+	Mirrorlist string
+	Metalink   string
+}
+
+// resolveURLs 替换地址模板中的变量；过期版本有 vault 地址时只使用 vault，mirrorlist / metalink 只提供当前版本
+func resolveURLs(url string, baseURLs []string, mirrorlist, metalink, vaultURL string, expired bool, expand func(string) string) *RepoURLs {
+	if expired && vaultURL != "" {
+		return &RepoURLs{BaseURLs: []string{expand(vaultURL)}}
+	}
+	urls := &RepoURLs{}
+	for _, u := range append([]string{url}, baseURLs...) {
+		if u != "" {
+			urls.BaseURLs = append(urls.BaseURLs, expand(u))
+		}
+	}
+	if mirrorlist != "" {
+		urls.Mirrorlist = expand(mirrorlist)
+	}
+	if metalink != "" {
+		urls.Metalink = expand(metalink)
+	}
+	return urls
+}
+
+// Apply 将地址写入源，多个 baseurl 写成多行
+func (u *RepoURLs) Apply(section *Section) {
+	if len(u.BaseURLs) > 0 {
+		section.Set("baseurl", strings.Join(u.BaseURLs, "\n"))
+	}
+	if u.Mirrorlist != "" {
+		section.Set("mirrorlist", u.Mirrorlist)
+	}
+	if u.Metalink != "" {
+		section.Set("metalink", u.Metalink)
+	}
+}
+
+// expandVars 使用发行版的内置变量替换地址中的变量，见 NewVars
+func expandVars(s, distro, releasever, basearch string) string {
+	return NewVars(distro, releasever, basearch).Expand(s)
 }
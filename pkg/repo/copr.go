@@ -0,0 +1,155 @@
+package repo
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// CoprHost Fedora Copr 构建服务
+	CoprHost = "copr.fedorainfracloud.org"
+	// CoprDownload Copr 构建结果的下载地址
+	CoprDownload = "https://download.copr.fedorainfracloud.org/results"
+	// CoprScheme yuv repo add / remove 中 Copr 项目的前缀，如 copr:owner/project
+	CoprScheme = "copr:"
+	// coprTag 写在 yuv 生成的 Copr .repo 文件头部的标记，后跟项目名称
+	coprTag = "yuv copr:"
+)
+
+// coprProjectPattern Copr 项目 owner/project，owner 以 @ 开头时为用户组
+var coprProjectPattern = regexp.MustCompile(`^@?[A-Za-z0-9][A-Za-z0-9._-]*/[A-Za-z0-9][A-Za-z0-9._+-]*$`)
+
+// CoprProject Fedora Copr 项目
+type CoprProject struct {
+	Owner string // 用户名，用户组以 @ 开头
+	Name  string // 项目名称
+}
+
+// ParseCoprProject 解析 owner/project 或 @group/project，可带 copr: 前缀
+func ParseCoprProject(s string) (*CoprProject, error) {
+	project := strings.TrimPrefix(s, CoprScheme)
+	if !coprProjectPattern.MatchString(project) {
+		return nil, fmt.Errorf("invalid copr project %q, expected owner/project", s)
+	}
+	owner, name, _ := strings.Cut(project, "/")
+	return &CoprProject{Owner: owner, Name: name}, nil
+}
+
+// ValidateCoprProject 校验 Copr 项目名称 owner/project
+func ValidateCoprProject(project string) error {
+	_, err := ParseCoprProject(project)
+	return err
+}
+
+// String 返回 owner/project
+func (p *CoprProject) String() string {
+	return p.Owner + "/" + p.Name
+}
+
+// Chroot 返回发行版对应的 Copr chroot，如 fedora-40-aarch64、centos-stream-9-x86_64、epel-9-x86_64：
+// Fedora 使用完整版本号，CentOS Stream 使用 centos-stream，其他 EL 发行版使用 epel 与主版本号
+func (p *CoprProject) Chroot(distro, releasever, basearch string) string {
+	major, minor, _ := strings.Cut(releasever, ".")
+	switch {
+	case distro == "fedora":
+		return fmt.Sprintf("fedora-%s-%s", major, basearch)
+	case distro == "centos" && minor == "" && major != "7":
+		return fmt.Sprintf("centos-stream-%s-%s", major, basearch)
+	}
+	return fmt.Sprintf("epel-%s-%s", major, basearch)
+}
+
+// results 返回项目构建结果的根地址
+func (p *CoprProject) results() string {
+	return fmt.Sprintf("%s/%s/%s", CoprDownload, p.Owner, p.Name)
+}
+
+// BaseURL 返回项目在指定发行版上的 baseurl
+func (p *CoprProject) BaseURL(distro, releasever, basearch string) string {
+	return fmt.Sprintf("%s/%s/", p.results(), p.Chroot(distro, releasever, basearch))
+}
+
+// GPGKeyURL 返回项目的签名密钥地址
+func (p *CoprProject) GPGKeyURL() string {
+	return p.results() + "/pubkey.gpg"
+}
+
+// RepoID 返回与 dnf copr 插件一致的源 ID，用户组 @group 写作 group_group
+func (p *CoprProject) RepoID() string {
+	owner := p.Owner
+	if strings.HasPrefix(owner, "@") {
+		owner = "group_" + owner[1:]
+	}
+	return fmt.Sprintf("copr:%s:%s:%s", CoprHost, owner, p.Name)
+}
+
+// FileName 返回项目在源配置目录中的文件名，与 dnf copr 插件一致
+func (p *CoprProject) FileName() string {
+	return "_" + p.RepoID() + ".repo"
+}
+
+// RepoFile 生成项目的 .repo 文件，选项与 dnf copr enable 生成的文件一致，
+// 文件头部写入 yuv 的标记，用于列出 yuv 添加的 Copr 源
+func (p *CoprProject) RepoFile(distro, releasever, basearch string, priority int) *RepoFile {
+	file := NewRepoFile()
+	file.AddComment(coprTag + " " + p.String())
+	section := file.AddSection(p.RepoID())
+	section.Set("name", fmt.Sprintf("Copr repo for %s owned by %s", p.Name, p.Owner))
+	section.Set("baseurl", p.BaseURL(distro, releasever, basearch))
+	section.Set("type", "rpm-md")
+	section.Set("skip_if_unavailable", "True")
+	section.SetBool("gpgcheck", true)
+	section.Set("gpgkey", p.GPGKeyURL())
+	section.SetBool("repo_gpgcheck", false)
+	section.SetBool("enabled", true)
+	section.SetBool("enabled_metadata", true)
+	if priority > 0 {
+		section.Set("priority", strconv.Itoa(priority))
+	}
+	return file
+}
+
+// taggedCoprProject 返回 yuv 生成的 Copr .repo 文件头部标记的项目，没有标记时返回空字符串
+func taggedCoprProject(file *RepoFile) string {
+	for _, comment := range file.Comments() {
+		if project, ok := strings.CutPrefix(comment, coprTag); ok {
+			return strings.TrimSpace(project)
+		}
+	}
+	return ""
+}
+
+// coprRepo 为 copr:owner/project 生成临时的源配置，使用 Copr 安装方式
+func coprRepo(spec string) (*Repo, error) {
+	project, err := ParseCoprProject(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &Repo{Name: CoprScheme + project.String(), Type: TypeThird, Copr: project.String(), Enabled: true}, nil
+}
+
+// coprInstaller 按 Copr 项目生成 .repo 文件
+type coprInstaller struct {
+	m *Manager
+}
+
+// Install 生成 Copr 项目的 .repo 文件，已添加时按当前系统重新生成
+func (i *coprInstaller) Install(tx *Transaction, target *InstallTarget) error {
+	project, err := ParseCoprProject(target.Repo.Copr)
+	if err != nil {
+		return err
+	}
+	file := project.RepoFile(target.Distro, target.Releasever, target.Basearch, target.Repo.Priority)
+	return tx.WriteFile(project.FileName(), file.Bytes(), 0644)
+}
+
+// Uninstall 删除 Copr 项目的 .repo 文件
+func (i *coprInstaller) Uninstall(tx *Transaction, target *InstallTarget) ([]string, error) {
+	project, err := ParseCoprProject(target.Repo.Copr)
+	if err != nil {
+		return nil, err
+	}
+	return i.m.removeRepoFile(tx, project.FileName())
+}
@@ -0,0 +1,152 @@
+package repo
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestCoprChroot(t *testing.T) {
+	project := &CoprProject{Owner: "owner", Name: "project"}
+	tests := []struct {
+		distro     string
+		releasever string
+		basearch   string
+		want       string
+	}{
+		{"fedora", "40", "x86_64", "fedora-40-x86_64"},
+		{"fedora", "41", "aarch64", "fedora-41-aarch64"},
+		{"centos", "9", "x86_64", "centos-stream-9-x86_64"},
+		{"centos", "10", "aarch64", "centos-stream-10-aarch64"},
+		{"centos", "7.9.2009", "x86_64", "epel-7-x86_64"},
+		{"centos", "7", "x86_64", "epel-7-x86_64"},
+		{"rocky", "9.3", "x86_64", "epel-9-x86_64"},
+		{"almalinux", "8.10", "aarch64", "epel-8-aarch64"},
+		{"rhel", "9", "x86_64", "epel-9-x86_64"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.distro+"-"+tt.releasever, func(t *testing.T) {
+			if got := project.Chroot(tt.distro, tt.releasever, tt.basearch); got != tt.want {
+				t.Errorf("Chroot(%s, %s, %s) = %s, want %s", tt.distro, tt.releasever, tt.basearch, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCoprProject(t *testing.T) {
+	tests := []struct {
+		input    string
+		wantID   string
+		wantFile string
+		wantErr  bool
+	}{
+		{
+			input:    "owner/project",
+			wantID:   "copr:copr.fedorainfracloud.org:owner:project",
+			wantFile: "_copr:copr.fedorainfracloud.org:owner:project.repo",
+		},
+		{
+			input:    "copr:owner/project",
+			wantID:   "copr:copr.fedorainfracloud.org:owner:project",
+			wantFile: "_copr:copr.fedorainfracloud.org:owner:project.repo",
+		},
+		{
+			input:    "@caddy/caddy",
+			wantID:   "copr:copr.fedorainfracloud.org:group_caddy:caddy",
+			wantFile: "_copr:copr.fedorainfracloud.org:group_caddy:caddy.repo",
+		},
+		{input: "owner", wantErr: true},
+		{input: "owner/project/extra", wantErr: true},
+		{input: "/project", wantErr: true},
+		{input: "@/project", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			project, err := ParseCoprProject(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseCoprProject(%s) error = nil, want error", tt.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCoprProject(%s) error = %v", tt.input, err)
+			}
+			if got := project.RepoID(); got != tt.wantID {
+				t.Errorf("RepoID() = %s, want %s", got, tt.wantID)
+			}
+			if got := project.FileName(); got != tt.wantFile {
+				t.Errorf("FileName() = %s, want %s", got, tt.wantFile)
+			}
+		})
+	}
+}
+
+func TestCoprRepoFile(t *testing.T) {
+	tests := []struct {
+		project    string
+		distro     string
+		releasever string
+		priority   int
+		wantURL    string
+		wantKey    string
+	}{
+		{
+			project:    "@caddy/caddy",
+			distro:     "rocky",
+			releasever: "9.3",
+			wantURL:    "https://download.copr.fedorainfracloud.org/results/@caddy/caddy/epel-9-x86_64/",
+			wantKey:    "https://download.copr.fedorainfracloud.org/results/@caddy/caddy/pubkey.gpg",
+		},
+		{
+			project:    "owner/project",
+			distro:     "fedora",
+			releasever: "40",
+			priority:   10,
+			wantURL:    "https://download.copr.fedorainfracloud.org/results/owner/project/fedora-40-x86_64/",
+			wantKey:    "https://download.copr.fedorainfracloud.org/results/owner/project/pubkey.gpg",
+		},
+		{
+			project:    "owner/project",
+			distro:     "centos",
+			releasever: "9",
+			wantURL:    "https://download.copr.fedorainfracloud.org/results/owner/project/centos-stream-9-x86_64/",
+			wantKey:    "https://download.copr.fedorainfracloud.org/results/owner/project/pubkey.gpg",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.project+"-"+tt.distro, func(t *testing.T) {
+			project, err := ParseCoprProject(tt.project)
+			if err != nil {
+				t.Fatalf("ParseCoprProject(%s) error = %v", tt.project, err)
+			}
+			if got := project.BaseURL(tt.distro, tt.releasever, "x86_64"); got != tt.wantURL {
+				t.Errorf("BaseURL() = %s, want %s", got, tt.wantURL)
+			}
+			if got := project.GPGKeyURL(); got != tt.wantKey {
+				t.Errorf("GPGKeyURL() = %s, want %s", got, tt.wantKey)
+			}
+
+			file := project.RepoFile(tt.distro, tt.releasever, "x86_64", tt.priority)
+			if got := taggedCoprProject(file); got != project.String() {
+				t.Errorf("taggedCoprProject() = %s, want %s", got, project.String())
+			}
+			section := file.Section(project.RepoID())
+			if section == nil {
+				t.Fatalf("RepoFile() has no section %s", project.RepoID())
+			}
+			if got := section.Value("baseurl"); got != tt.wantURL {
+				t.Errorf("baseurl = %s, want %s", got, tt.wantURL)
+			}
+			if got := section.Value("gpgkey"); got != tt.wantKey {
+				t.Errorf("gpgkey = %s, want %s", got, tt.wantKey)
+			}
+			priority, ok := section.Get("priority")
+			if tt.priority == 0 && ok {
+				t.Errorf("priority = %s, want unset", priority)
+			}
+			if tt.priority > 0 && priority != strconv.Itoa(tt.priority) {
+				t.Errorf("priority = %s, want %d", priority, tt.priority)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package repo
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"yuv/pkg/system"
+)
+
+// crbNames CRB 仓库在各版本中的名称：EL9 为 CRB，EL8 为 PowerTools
+var crbNames = []string{"crb", "powertools"}
+
+// isCRBRepo 判断源 ID 是否为 CRB / PowerTools 仓库：发行版自带的 crb、powertools，
+// yuv 生成的 <镜像源>-CRB、<镜像源>-PowerTools，以及 RHEL 的 codeready-builder-for-rhel-*-rpms
+func isCRBRepo(id string) bool {
+	id = strings.ToLower(id)
+	for _, name := range crbNames {
+		if id == name || strings.HasSuffix(id, "-"+name) {
+			return true
+		}
+	}
+	return strings.HasPrefix(id, "codeready-builder-for-rhel-") && strings.HasSuffix(id, "-rpms")
+}
+
+// hasCRB 发行版是否有 CRB / PowerTools 仓库，EL7 和 Fedora 没有
+func hasCRB(distro *system.Distro) bool {
+	major, _ := strconv.Atoi(strings.Split(distro.Version, ".")[0])
+	return distro.Name != "fedora" && major >= 8
+}
+
+// crbEnabled 当前是否已启用 CRB / PowerTools 仓库
+func (m *Manager) crbEnabled() (bool, error) {
+	files, err := m.loadRepoFiles()
+	if err != nil {
+		return false, err
+	}
+	for _, file := range files {
+		for _, section := range file.File.Sections {
+			if isCRBRepo(section.ID) && section.Enabled() {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// enableCRB 在事务中启用 CRB / PowerTools 仓库，已启用或发行版没有该仓库时不做修改。
+// RHEL 的仓库由 subscription-manager 管理，通过其命令启用
+func (m *Manager) enableCRB(tx *Transaction) error {
+	distro, err := m.detector().Detect()
+	if err != nil {
+		return fmt.Errorf("detect system failed: %v", err)
+	}
+	if !hasCRB(distro) {
+		return nil
+	}
+	files, err := m.loadRepoFiles()
+	if err != nil {
+		return err
+	}
+
+	var matches []*SectionMatch
+	for _, file := range files {
+		for _, section := range file.File.Sections {
+			if !isCRBRepo(section.ID) {
+				continue
+			}
+			if section.Enabled() {
+				return nil
+			}
+			matches = append(matches, &SectionMatch{Path: file.Path, File: file.File, Section: section})
+		}
+	}
+	if len(matches) == 0 {
+		return fmt.Errorf("no CRB or PowerTools repo is configured for %s %s, run yuv repo use <mirror> first", distro.Name, distro.Version)
+	}
+
+	touched := map[string]*RepoFile{}
+	for _, match := range matches {
+		if filepath.Base(match.Path) == "redhat.repo" {
+			if m.Root != "" {
+				return fmt.Errorf("cannot enable %s in an alternate root, it is managed by subscription-manager", match.Section.ID)
+			}
+			tx.Run(false, "subscription-manager", "repos", "--enable", match.Section.ID)
+			continue
+		}
+		match.Section.SetBool("enabled", true)
+		touched[match.Path] = match.File
+	}
+	return m.stageRepoFiles(tx, touched)
+}
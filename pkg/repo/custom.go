@@ -0,0 +1,188 @@
+package repo
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"yuv/internal/utils"
+)
+
+// repoIDPattern dnf 允许的源 ID 字符
+var repoIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:-]*$`)
+
+// CustomRepo 自定义源参数
+type CustomRepo struct {
+	ID       string       // 源 ID，同时作为文件名
+	Name     string       // 源描述，为空时使用 ID
+	BaseURLs []string     // baseurl 列表
+	GPGKeys  []string     // gpgkey 列表，为空时关闭 gpgcheck
+	Priority int          // 优先级，0 表示不设置
+	Excludes []string     // 排除的软件包
+	Disabled bool         // 添加后是否禁用
+	Proxy    *utils.Proxy // 访问该源使用的代理，为 nil 时沿用全局代理
+}
+
+// Validate 校验自定义源参数
+func (c *CustomRepo) Validate() error {
+	if !repoIDPattern.MatchString(c.ID) {
+		return fmt.Errorf("invalid repo id %q", c.ID)
+	}
+	if len(c.BaseURLs) == 0 {
+		return fmt.Errorf("at least one baseurl is required")
+	}
+	for _, u := range append(append([]string{}, c.BaseURLs...), c.GPGKeys...) {
+		if err := validateURL(u); err != nil {
+			return err
+		}
+	}
+	if c.Proxy != nil {
+		if err := c.Proxy.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.Priority < 0 || c.Priority > 99 {
+		return fmt.Errorf("priority %d out of range 1-99, use 0 to leave it unset", c.Priority)
+	}
+	return nil
+}
+
+// RepoFile 生成自定义源的 .repo 文件
+func (c *CustomRepo) RepoFile() *RepoFile {
+	file := NewRepoFile()
+	section := file.AddSection(c.ID)
+	name := c.Name
+	if name == "" {
+		name = c.ID
+	}
+	section.Set("name", name)
+	section.Set("baseurl", strings.Join(c.BaseURLs, "\n"))
+	section.SetBool("enabled", !c.Disabled)
+	section.SetBool("gpgcheck", len(c.GPGKeys) > 0)
+	if len(c.GPGKeys) > 0 {
+		section.Set("gpgkey", strings.Join(c.GPGKeys, "\n"))
+	}
+	if c.Priority > 0 {
+		section.Set("priority", strconv.Itoa(c.Priority))
+	}
+	if len(c.Excludes) > 0 {
+		section.Set("exclude", strings.Join(c.Excludes, " "))
+	}
+	if c.Proxy != nil {
+		setSectionProxy(section, c.Proxy)
+	}
+	return file
+}
+
+// AddCustom 根据参数生成并写入自定义源，返回写入的文件路径
+func (m *Manager) AddCustom(c *CustomRepo) (string, error) {
+	if err := c.Validate(); err != nil {
+		return "", err
+	}
+
+	repoFile := filepath.Join(m.RepoDir, c.ID+".repo")
+	file := c.RepoFile()
+	if err := m.checkNewRepo(repoFile, file); err != nil {
+		return "", err
+	}
+	if err := m.writeNewRepo(filepath.Base(repoFile), file); err != nil {
+		return "", err
+	}
+
+	return repoFile, nil
+}
+
+// AddRemote 下载并校验远程 .repo 文件后安装到源配置目录，返回其中的源 ID
+func (m *Manager) AddRemote(rawURL string) ([]string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid repo file url %q", rawURL)
+	}
+
+	file, err := fetchRepoFile(utils.DefaultClient, rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// 优先使用 URL 中的文件名，否则使用第一个源 ID
+	name := path.Base(u.Path)
+	if !strings.HasSuffix(name, ".repo") || !repoIDPattern.MatchString(strings.TrimSuffix(name, ".repo")) {
+		name = file.Sections[0].ID + ".repo"
+	}
+	repoFile := filepath.Join(m.RepoDir, name)
+	if err := m.checkNewRepo(repoFile, file); err != nil {
+		return nil, err
+	}
+	if err := m.writeNewRepo(name, file); err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, section := range file.Sections {
+		ids = append(ids, section.ID)
+	}
+	return ids, nil
+}
+
+// writeNewRepo 通过事务写入新的源文件，校验失败时不修改源配置目录
+func (m *Manager) writeNewRepo(name string, file *RepoFile) error {
+	tx, err := m.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	if err := tx.WriteFile(name, file.Bytes(), 0644); err != nil {
+		return err
+	}
+	return m.commit(tx)
+}
+
+// validateRemoteRepoFile 校验下载的 .repo 文件：至少一个源，每个源都有合法 ID 和地址
+func validateRemoteRepoFile(file *RepoFile) error {
+	if len(file.Sections) == 0 {
+		return fmt.Errorf("no repository defined")
+	}
+	for _, section := range file.Sections {
+		if section.ID == "main" {
+			return fmt.Errorf("[main] section is not allowed in a repo file")
+		}
+		if !repoIDPattern.MatchString(section.ID) {
+			return fmt.Errorf("invalid repo id %q", section.ID)
+		}
+		if section.Value("baseurl") == "" && section.Value("mirrorlist") == "" && section.Value("metalink") == "" {
+			return fmt.Errorf("repo %s has no baseurl, mirrorlist or metalink", section.ID)
+		}
+		if _, err := section.Bool("enabled", true); err != nil {
+			return fmt.Errorf("repo %s: enabled: %v", section.ID, err)
+		}
+		if _, err := section.Bool("gpgcheck", false); err != nil {
+			return fmt.Errorf("repo %s: gpgcheck: %v", section.ID, err)
+		}
+	}
+	return nil
+}
+
+// checkNewRepo 检查新源文件不会覆盖已有文件，且源 ID 与现有源不冲突
+func (m *Manager) checkNewRepo(repoFile string, file *RepoFile) error {
+	if _, err := os.Stat(repoFile); err == nil {
+		return fmt.Errorf("repo file %s already exists", repoFile)
+	}
+
+	existing, err := m.loadRepoFiles()
+	if err != nil {
+		return err
+	}
+	for _, section := range file.Sections {
+		for _, loaded := range existing {
+			if loaded.File.Section(section.ID) != nil {
+				return fmt.Errorf("repo %s already exists in %s", section.ID, loaded.Path)
+			}
+		}
+	}
+	return nil
+}
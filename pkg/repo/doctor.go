@@ -0,0 +1,457 @@
+package repo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"yuv/pkg/system"
+)
+
+// Severity 诊断问题的严重程度
+type Severity string
+
+const (
+	SeverityError   Severity = "error"   // 会导致 dnf 失败或装到错误的软件包
+	SeverityWarning Severity = "warning" // 存在安全或维护隐患
+)
+
+// 诊断问题类型
+const (
+	FindingParseError       = "parse-error"       // .repo 文件无法解析
+	FindingDuplicateID      = "duplicate-id"      // 源 ID 重复定义
+	FindingInvalidValue     = "invalid-value"     // enabled、gpgcheck、priority 取值非法
+	FindingPriorityConflict = "priority-conflict" // 多个启用的源提供同一仓库
+	FindingGPGCheckDisabled = "gpgcheck-disabled" // 关闭了签名校验
+	FindingMissingGPGKey    = "missing-gpgkey"    // 开启了签名校验但密钥缺失
+	FindingWrongRelease     = "wrong-release"     // 地址写死了其他主版本号
+	FindingDeadMirror       = "dead-mirror"       // 指向已下线的 CentOS 镜像
+	FindingUnreachable      = "unreachable"       // 无法下载元数据
+	FindingLeftoverFile     = "leftover-file"     // 软件包升级遗留的 .rpmnew 等文件
+)
+
+// leftoverSuffixes rpm 升级配置文件时遗留的文件后缀，dnf 不会读取
+var leftoverSuffixes = []string{".rpmnew", ".rpmsave", ".rpmorig"}
+
+// Finding 诊断发现的一个问题
+type Finding struct {
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"`
+	Repo     string   `json:"repo,omitempty"` // 源 ID，文件级问题为空
+	File     string   `json:"file"`
+	Message  string   `json:"message"`
+	Fix      string   `json:"fix,omitempty"` // 可以安全自动修复时的修复方式
+	Fixed    bool     `json:"fixed,omitempty"`
+	apply    func(tx *Transaction, touched map[string]*RepoFile) error
+}
+
+// doctorSection 参与诊断的源
+type doctorSection struct {
+	path    string
+	file    *RepoFile
+	section *Section
+}
+
+// Doctor 结合当前系统诊断源配置目录中的所有文件，fix 为 true 时自动修复可以安全修复的问题：
+// 删除重复且定义相同的源、禁用其他主版本的源、把已下线镜像迁移到 vault、删除遗留文件。
+// 修复前创建快照；SkipVerify 时不检查源能否访问
+func (m *Manager) Doctor(fix bool) ([]*Finding, error) {
+	distro, err := m.detector().Detect()
+	if err != nil {
+		return nil, fmt.Errorf("detect system failed: %v", err)
+	}
+	expand, err := m.expander()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ioutil.ReadDir(m.RepoDir)
+	if err != nil {
+		return nil, fmt.Errorf("read repo directory failed: %v", err)
+	}
+	var findings []*Finding
+	var sections []*doctorSection
+	for _, info := range entries {
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		path := filepath.Join(m.RepoDir, info.Name())
+		if suffix := leftoverSuffix(info.Name()); suffix != "" {
+			name := info.Name()
+			findings = append(findings, &Finding{
+				Severity: SeverityWarning,
+				Code:     FindingLeftoverFile,
+				File:     path,
+				Message:  fmt.Sprintf("%s file left by a package upgrade, dnf ignores it", suffix),
+				Fix:      "delete the file (kept in the backup)",
+				apply: func(tx *Transaction, touched map[string]*RepoFile) error {
+					tx.Remove(name)
+					return nil
+				},
+			})
+			continue
+		}
+		if !strings.HasSuffix(info.Name(), ".repo") {
+			continue
+		}
+		file, err := LoadRepoFile(path)
+		if err != nil {
+			findings = append(findings, &Finding{Severity: SeverityError, Code: FindingParseError, File: path, Message: err.Error()})
+			continue
+		}
+		for _, section := range file.Sections {
+			sections = append(sections, &doctorSection{path: path, file: file, section: section})
+		}
+	}
+
+	// 重复定义的源只报告 duplicate-id，不再参与其他检查
+	duplicates, unique := findDuplicateIDs(sections)
+	findings = append(findings, duplicates...)
+	findings = append(findings, findPriorityConflicts(unique, expand, distro)...)
+
+	// EOL 系统中指向已下线镜像的源可以迁移到官方 vault
+	var vault *Layout
+	if distro.IsEOL(time.Now()) {
+		vault, _ = vaultLayout(distro, "")
+	}
+	for _, s := range unique {
+		findings = append(findings, m.checkDoctorSection(s, distro, vault, expand)...)
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Severity != findings[j].Severity {
+			return findings[i].Severity == SeverityError
+		}
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		return findings[i].Repo < findings[j].Repo
+	})
+
+	if fix {
+		if err := m.applyFixes(findings); err != nil {
+			return findings, err
+		}
+	}
+	return findings, nil
+}
+
+// checkDoctorSection 检查单个源的取值、签名校验、版本和可访问性
+func (m *Manager) checkDoctorSection(s *doctorSection, distro *system.Distro, vault *Layout, expand func(string) string) []*Finding {
+	var findings []*Finding
+	add := func(severity Severity, code, format string, args ...interface{}) *Finding {
+		f := &Finding{Severity: severity, Code: code, Repo: s.section.ID, File: s.path, Message: fmt.Sprintf(format, args...)}
+		findings = append(findings, f)
+		return f
+	}
+
+	for _, key := range []string{"enabled", "gpgcheck"} {
+		if _, err := s.section.Bool(key, true); err != nil {
+			add(SeverityError, FindingInvalidValue, "%s: %v", key, err)
+		}
+	}
+	if _, err := sectionPriority(s.section); err != nil {
+		add(SeverityError, FindingInvalidValue, "priority: %v", err)
+	}
+	if !s.section.Enabled() {
+		return findings
+	}
+
+	// 签名校验
+	gpgcheck, err := s.section.Bool("gpgcheck", false)
+	keys := s.section.Values("gpgkey")
+	switch {
+	case err != nil:
+	case !gpgcheck:
+		add(SeverityWarning, FindingGPGCheckDisabled, "gpgcheck is disabled, package signatures are not verified")
+	case len(keys) == 0:
+		add(SeverityWarning, FindingMissingGPGKey, "gpgcheck is enabled but no gpgkey is configured, only keys already imported into rpm can be used")
+	}
+	for _, key := range keys {
+		path := expand(key)
+		if !strings.HasPrefix(path, "file://") {
+			continue
+		}
+		if _, err := os.Stat(strings.TrimPrefix(path, "file://")); err != nil {
+			severity := SeverityWarning
+			if gpgcheck {
+				severity = SeverityError
+			}
+			add(severity, FindingMissingGPGKey, "gpgkey %s does not exist", key)
+		}
+	}
+
+	// 地址写死了其他主版本号
+	major := strings.Split(distro.Version, ".")[0]
+	for _, raw := range sectionURLs(s.section) {
+		if release, ok := urlRelease(expand(raw)); ok && release != major {
+			f := add(SeverityError, FindingWrongRelease, "%s is for release %s, but the system is %s %s", raw, release, distro.Name, distro.Version)
+			f.Fix = "disable the repo"
+			f.apply = func(tx *Transaction, touched map[string]*RepoFile) error {
+				s.section.SetBool("enabled", false)
+				touched[s.path] = s.file
+				return nil
+			}
+			return findings
+		}
+	}
+
+	// 已下线的 CentOS 镜像
+	if dead, ok := deadURL(s.section); ok {
+		f := add(SeverityError, FindingDeadMirror, "%s points to a retired CentOS mirror", dead)
+		if vault != nil {
+			if to, err := vaultURL(vault, distro.Name, distro.Version, s.section); err == nil && m.validateURL(s.section, to, expand) == nil {
+				f.Fix = "move to " + to
+				f.apply = func(tx *Transaction, touched map[string]*RepoFile) error {
+					if _, err := moveToVault(s.section, vault, distro); err != nil {
+						return err
+					}
+					touched[s.path] = s.file
+					return nil
+				}
+			}
+		}
+		return findings
+	}
+
+	if !m.SkipVerify {
+		if err := ValidateSection(s.section, expand); err != nil {
+			add(SeverityError, FindingUnreachable, "%v", err)
+		}
+	}
+	return findings
+}
+
+// validateURL 确认源改用 baseurl 后可以下载元数据，沿用源的代理设置，SkipVerify 时跳过
+func (m *Manager) validateURL(source *Section, baseurl string, expand func(string) string) error {
+	if m.SkipVerify {
+		return nil
+	}
+	section := NewRepoFile().AddSection(source.ID)
+	section.Set("baseurl", baseurl)
+	for _, key := range proxyKeys {
+		if value, ok := source.Get(key); ok {
+			section.Set(key, value)
+		}
+	}
+	return ValidateSection(section, expand)
+}
+
+// findDuplicateIDs 查找重复定义的源 ID，地址相同的重复定义可以删除；同时返回每个 ID 的第一个定义
+func findDuplicateIDs(sections []*doctorSection) ([]*Finding, []*doctorSection) {
+	var findings []*Finding
+	var unique []*doctorSection
+	first := map[string]*doctorSection{}
+	for _, s := range sections {
+		prev, ok := first[s.section.ID]
+		if !ok {
+			first[s.section.ID] = s
+			unique = append(unique, s)
+			continue
+		}
+		f := &Finding{
+			Severity: SeverityError,
+			Code:     FindingDuplicateID,
+			Repo:     s.section.ID,
+			File:     s.path,
+			Message:  fmt.Sprintf("repo id is already defined in %s", filepath.Base(prev.path)),
+		}
+		if sourceKey(prev.section) == sourceKey(s.section) {
+			f.Fix = "remove the duplicate definition"
+			f.apply = func(tx *Transaction, touched map[string]*RepoFile) error {
+				removeSection(s.file, s.section)
+				touched[s.path] = s.file
+				return nil
+			}
+		}
+		findings = append(findings, f)
+	}
+	return findings, unique
+}
+
+// findPriorityConflicts 查找提供同一仓库的多个启用的源，优先级相同时 dnf 无法确定使用哪一个
+func findPriorityConflicts(sections []*doctorSection, expand func(string) string, distro *system.Distro) []*Finding {
+	var findings []*Finding
+	major := strings.Split(distro.Version, ".")[0]
+	seen := map[string]*doctorSection{}
+	for _, s := range sections {
+		if !s.section.Enabled() {
+			continue
+		}
+		key := contentKey(s.section, expand, major)
+		if key == "" {
+			continue
+		}
+		prev, ok := seen[key]
+		if !ok {
+			seen[key] = s
+			continue
+		}
+		priority, _ := sectionPriority(s.section)
+		prevPriority, _ := sectionPriority(prev.section)
+		message := fmt.Sprintf("provides the same repository as %s with the same priority %d, packages may come from either", prev.section.ID, priority)
+		if priority != prevPriority {
+			message = fmt.Sprintf("provides the same repository as %s (priority %d vs %d), the one with the higher value is never used", prev.section.ID, priority, prevPriority)
+		}
+		findings = append(findings, &Finding{Severity: SeverityWarning, Code: FindingPriorityConflict, Repo: s.section.ID, File: s.path, Message: message})
+	}
+	return findings
+}
+
+// applyFixes 在一个事务中应用所有可修复的问题，提交前创建快照
+func (m *Manager) applyFixes(findings []*Finding) error {
+	var fixable []*Finding
+	for _, f := range findings {
+		if f.apply != nil {
+			fixable = append(fixable, f)
+		}
+	}
+	if len(fixable) == 0 {
+		return nil
+	}
+
+	tx, err := m.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	touched := map[string]*RepoFile{}
+	for _, f := range fixable {
+		if err := f.apply(tx, touched); err != nil {
+			return err
+		}
+	}
+	if err := m.stageRepoFiles(tx, touched); err != nil {
+		return err
+	}
+	if err := m.apply(tx, true); err != nil {
+		return err
+	}
+	if !m.DryRun {
+		for _, f := range fixable {
+			f.Fixed = true
+		}
+	}
+	return nil
+}
+
+// leftoverSuffix 返回遗留文件的后缀，不是遗留文件时返回空字符串
+func leftoverSuffix(name string) string {
+	for _, suffix := range leftoverSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return suffix
+		}
+	}
+	return ""
+}
+
+// sectionPriority 获取源的优先级，未设置时为 dnf 默认的 99
+func sectionPriority(section *Section) (int, error) {
+	value, ok := section.Get("priority")
+	if !ok {
+		return 99, nil
+	}
+	priority, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || priority < 1 || priority > 99 {
+		return 99, fmt.Errorf("invalid priority %q, must be an integer from 1 to 99", value)
+	}
+	return priority, nil
+}
+
+// sectionURLs 返回源的所有地址（baseurl、mirrorlist、metalink）
+func sectionURLs(section *Section) []string {
+	var urls []string
+	for _, key := range []string{"baseurl", "mirrorlist", "metalink"} {
+		urls = append(urls, section.Values(key)...)
+	}
+	return urls
+}
+
+// sourceKey 用于判断两个源的地址是否完全相同
+func sourceKey(section *Section) string {
+	return strings.Join(sectionURLs(section), "\n")
+}
+
+// removeSection 删除指定的节，同一文件中有重复 ID 时只删除这一个
+func removeSection(file *RepoFile, section *Section) {
+	for i, s := range file.Sections {
+		if s == section {
+			file.Sections = append(file.Sections[:i], file.Sections[i+1:]...)
+			return
+		}
+	}
+}
+
+// contentKey 返回与镜像站无关的仓库标识：baseurl 中从版本目录开始的路径（如 9/baseos/x86_64/os），
+// 只有 mirrorlist / metalink 时使用完整地址，无法识别时返回空字符串
+func contentKey(section *Section, expand func(string) string, major string) string {
+	if baseurls := section.Values("baseurl"); len(baseurls) > 0 {
+		u, err := url.Parse(expand(baseurls[0]))
+		if err != nil {
+			return ""
+		}
+		segments := strings.Split(strings.Trim(strings.ToLower(u.Path), "/"), "/")
+		for i, segment := range segments {
+			if segment == major || strings.HasPrefix(segment, major+".") || segment == major+"-stream" {
+				return strings.Join(segments[i:], "/")
+			}
+		}
+		return ""
+	}
+	for _, key := range []string{"mirrorlist", "metalink"} {
+		if value := section.Value(key); value != "" {
+			return expand(value)
+		}
+	}
+	return ""
+}
+
+var (
+	// taggedRelease 带前缀的版本目录，如 el9、epel-9、fc40
+	taggedRelease = regexp.MustCompile(`^(?:el|rhel|epel-?|fc)(\d+)$`)
+	// numericRelease 数字版本目录，如 9、7.9.2009、8-stream
+	numericRelease = regexp.MustCompile(`^(\d+)(?:\.\d+)*(?:-stream)?$`)
+	// releaseParents 数字版本目录的上一级目录
+	releaseParents = map[string]bool{
+		"": true, "centos": true, "centos-vault": true, "centos-stream": true, "rocky": true, "rockylinux": true,
+		"almalinux": true, "alma": true, "el": true, "rhel": true, "epel": true, "releases": true, "updates": true,
+	}
+)
+
+// urlRelease 找出地址中写死的主版本号：路径中的版本目录，或 mirrorlist / metalink 的 release、repo 参数
+func urlRelease(raw string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	segments := strings.Split(strings.TrimPrefix(strings.ToLower(u.Path), "/"), "/")
+	for i, segment := range segments {
+		if match := taggedRelease.FindStringSubmatch(segment); match != nil {
+			return match[1], true
+		}
+		parent := ""
+		if i > 0 {
+			parent = segments[i-1]
+		}
+		if match := numericRelease.FindStringSubmatch(segment); match != nil && releaseParents[parent] {
+			return match[1], true
+		}
+	}
+	for _, key := range []string{"release", "repo"} {
+		value := strings.ToLower(u.Query().Get(key))
+		if match := taggedRelease.FindStringSubmatch(value); match != nil {
+			return match[1], true
+		}
+		if match := numericRelease.FindStringSubmatch(value); match != nil && key == "release" {
+			return match[1], true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,203 @@
+package repo
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"yuv/pkg/system"
+)
+
+const (
+	// OfficialVault CentOS 官方 vault 地址
+	OfficialVault = "https://vault.centos.org"
+)
+
+// deadHosts 已停止服务的 CentOS 镜像地址
+var deadHosts = []string{"mirrorlist.centos.org", "mirror.centos.org"}
+
+// EOLFix 一个迁移到 vault 的源
+type EOLFix struct {
+	ID   string `json:"id"`
+	File string `json:"file"`
+	From string `json:"from"` // 原来的 mirrorlist 或 baseurl
+	To   string `json:"to"`   // vault 中的地址
+}
+
+// EOLReport fix-eol 的检测与修复结果
+type EOLReport struct {
+	Distro  string    `json:"distro"`
+	Version string    `json:"version"`
+	EOL     time.Time `json:"eol,omitzero"` // 停止维护的日期，零值表示仍在维护
+	Vault   string    `json:"vault"`        // 使用的 vault 镜像
+	Fixes   []*EOLFix `json:"fixes,omitempty"`
+}
+
+// officialVault 官方 vault 的目录布局，与公共镜像源的 vault 布局一致
+func officialVault() *Repo {
+	dirs := &MirrorDirs{CentOS: OfficialVault, CentOSVault: OfficialVault, CentOSStream: OfficialVault}
+	return &Repo{Name: "vault.centos.org", Type: TypePublic, Layouts: dirs.StandardLayouts()}
+}
+
+// FixEOL 检测系统是否已停止维护，是则把仍指向已下线 CentOS 镜像的源改为 vault 地址并注释掉 mirrorlist。
+// mirror 为空时使用官方 vault，否则使用源目录中对应公共镜像源的 vault 目录。
+// 修改前创建快照，写入前校验新地址的元数据（SkipVerify 时跳过）
+func (m *Manager) FixEOL(mirror string) (*EOLReport, error) {
+	distro, err := m.detector().Detect()
+	if err != nil {
+		return nil, fmt.Errorf("detect system failed: %v", err)
+	}
+	report := &EOLReport{Distro: distro.Name, Version: distro.Version}
+	if !distro.IsEOL(time.Now()) {
+		return report, nil
+	}
+	report.EOL, _ = distro.EOLDate()
+
+	layout, err := vaultLayout(distro, mirror)
+	if err != nil {
+		return nil, err
+	}
+	report.Vault = officialVault().Name
+	if mirror != "" {
+		report.Vault = mirror
+	}
+
+	files, err := m.loadRepoFiles()
+	if err != nil {
+		return nil, err
+	}
+	touched := map[string]*RepoFile{}
+	for _, file := range files {
+		for _, section := range file.File.Sections {
+			fix, err := moveToVault(section, layout, distro)
+			if err != nil {
+				return nil, err
+			}
+			if fix == nil {
+				continue
+			}
+			fix.File = file.Path
+			touched[file.Path] = file.File
+			report.Fixes = append(report.Fixes, fix)
+		}
+	}
+	if len(touched) == 0 {
+		return report, nil
+	}
+
+	tx, err := m.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	if err := m.stageRepoFiles(tx, touched); err != nil {
+		return nil, err
+	}
+	if err := m.commit(tx); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// vaultLayout 获取 vault 镜像针对当前系统的目录布局，mirror 为空时使用官方 vault
+func vaultLayout(distro *system.Distro, mirror string) (*Layout, error) {
+	vault := officialVault()
+	if mirror != "" {
+		var err error
+		if vault, err = GetRepoByName(mirror); err != nil {
+			return nil, err
+		}
+	}
+	layout := vault.Layout(distro.Name, distro.Version)
+	if layout == nil || !strings.Contains(layout.VaultURL, "$component") {
+		return nil, fmt.Errorf("repo %s does not provide a vault for %s %s", vault.Name, distro.Name, distro.Version)
+	}
+	return layout, nil
+}
+
+// moveToVault 把指向已下线镜像的源改为 vault 地址并注释掉 mirrorlist / metalink，源未受影响时返回 nil
+func moveToVault(section *Section, layout *Layout, distro *system.Distro) (*EOLFix, error) {
+	from, ok := deadURL(section)
+	if !ok {
+		return nil, nil
+	}
+	to, err := vaultURL(layout, distro.Name, distro.Version, section)
+	if err != nil {
+		return nil, fmt.Errorf("repo %s: %v", section.ID, err)
+	}
+
+	// 优先恢复被注释掉的 baseurl，保持原文件中的位置
+	if _, ok := section.Get("baseurl"); !ok {
+		section.Uncomment("baseurl")
+	}
+	section.Set("baseurl", to)
+	section.Comment("mirrorlist")
+	section.Comment("metalink")
+	return &EOLFix{ID: section.ID, From: from, To: to}, nil
+}
+
+// deadURL 返回源中指向已下线镜像的地址
+func deadURL(section *Section) (string, bool) {
+	for _, key := range []string{"baseurl", "mirrorlist", "metalink"} {
+		for _, value := range section.Values(key) {
+			if isDeadURL(value) {
+				return value, true
+			}
+		}
+	}
+	return "", false
+}
+
+// isDeadURL 地址是否位于已下线的 CentOS 镜像
+func isDeadURL(value string) bool {
+	u, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+	for _, host := range deadHosts {
+		if strings.EqualFold(u.Hostname(), host) {
+			return true
+		}
+	}
+	return false
+}
+
+// vaultURL 推算源在 vault 中的地址：优先沿用原 baseurl（含被注释的）中版本目录之后的路径，
+// 以保留 SIG 等非标准目录；只有 mirrorlist 时按其 repo 参数套用 vault 布局。$basearch 保留为变量
+func vaultURL(layout *Layout, distro, releasever string, section *Section) (string, error) {
+	idx := strings.Index(layout.VaultURL, "$component")
+	prefix := expandVars(layout.VaultURL[:idx], distro, releasever, "$basearch")
+
+	baseurls := section.Values("baseurl")
+	if commented, ok := section.Commented("baseurl"); ok {
+		baseurls = append(baseurls, commented)
+	}
+	for _, baseurl := range baseurls {
+		if !isDeadURL(baseurl) {
+			continue
+		}
+		// 路径形如 /centos/$releasever/os/$basearch/ 或 /$contentdir/$stream/BaseOS/$basearch/os/
+		u, err := url.Parse(baseurl)
+		if err != nil {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 3)
+		if len(parts) == 3 && parts[2] != "" {
+			return prefix + parts[2], nil
+		}
+	}
+
+	for _, mirrorlist := range append(section.Values("mirrorlist"), section.Values("metalink")...) {
+		u, err := url.Parse(mirrorlist)
+		if err != nil || !isDeadURL(mirrorlist) {
+			continue
+		}
+		if component := u.Query().Get("repo"); component != "" {
+			suffix := strings.ReplaceAll(layout.VaultURL[idx:], "$component", component)
+			return prefix + expandVars(suffix, distro, releasever, "$basearch"), nil
+		}
+	}
+
+	return "", fmt.Errorf("cannot determine vault path")
+}
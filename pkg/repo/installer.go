@@ -0,0 +1,291 @@
+package repo
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"yuv/internal/utils"
+)
+
+// InstallMethod 源的安装方式
+type InstallMethod string
+
+const (
+	InstallTemplate   InstallMethod = "template"    // 按地址模板生成 .repo 文件
+	InstallReleaseRPM InstallMethod = "release_rpm" // 安装提供 .repo 文件和密钥的 release RPM
+	InstallRepoFile   InstallMethod = "repo_file"   // 下载远程 .repo 文件
+	InstallCopr       InstallMethod = "copr"        // 按 Copr 项目生成 .repo 文件
+)
+
+// InstallMethods 支持的安装方式
+var InstallMethods = []InstallMethod{InstallTemplate, InstallReleaseRPM, InstallRepoFile, InstallCopr}
+
+// RepoInstaller 源的安装方式，把添加和删除源所需的文件变更与命令加入事务
+type RepoInstaller interface {
+	// Install 把添加源所需的文件变更和命令加入事务
+	Install(tx *Transaction, target *InstallTarget) error
+	// Uninstall 把撤销 Install 所做变更的文件删除和命令加入事务，返回被删除的源，没有添加过该源时返回空列表
+	Uninstall(tx *Transaction, target *InstallTarget) ([]string, error)
+}
+
+// InstallTarget 要安装的源及当前系统信息
+type InstallTarget struct {
+	Repo       *Repo
+	Distro     string
+	Releasever string
+	Basearch   string
+}
+
+// InstallMethod 返回源在指定发行版上的安装方式：源目录中显式配置了 installer 时使用该方式，
+// 否则依次根据 copr、release_rpm、repo_file 推断，都没有时按地址模板生成 .repo 文件
+func (r *Repo) InstallMethod(distro, releasever, basearch string) InstallMethod {
+	switch {
+	case r.Installer != "":
+		return r.Installer
+	case r.Copr != "":
+		return InstallCopr
+	case r.ReleaseRPMURL(distro, releasever, basearch) != "":
+		return InstallReleaseRPM
+	case r.RepoFileURL(distro, releasever, basearch) != "":
+		return InstallRepoFile
+	}
+	return InstallTemplate
+}
+
+// Installer 返回源在当前系统上使用的安装方式
+func (m *Manager) Installer(target *InstallTarget) (RepoInstaller, error) {
+	method := target.Repo.InstallMethod(target.Distro, target.Releasever, target.Basearch)
+	switch method {
+	case InstallTemplate:
+		return &templateInstaller{m: m}, nil
+	case InstallReleaseRPM:
+		return &releaseRPMInstaller{m: m}, nil
+	case InstallRepoFile:
+		return &repoFileInstaller{m: m}, nil
+	case InstallCopr:
+		return &coprInstaller{m: m}, nil
+	}
+	return nil, fmt.Errorf("unknown installer %q", method)
+}
+
+// installTarget 检测当前系统，生成源的安装目标
+func (m *Manager) installTarget(repo *Repo) (*InstallTarget, error) {
+	detector := m.detector()
+	distro, err := detector.GetDistroName()
+	if err != nil {
+		return nil, fmt.Errorf("get distro name failed: %v", err)
+	}
+	releasever, err := detector.GetReleasever()
+	if err != nil {
+		return nil, fmt.Errorf("get releasever failed: %v", err)
+	}
+	basearch, err := detector.GetBasearch()
+	if err != nil {
+		return nil, fmt.Errorf("get basearch failed: %v", err)
+	}
+	return &InstallTarget{Repo: repo, Distro: distro, Releasever: releasever, Basearch: basearch}, nil
+}
+
+// removeRepoFile 标记删除源配置目录中的 .repo 文件，返回其中的源 ID，文件不存在时返回空列表
+func (m *Manager) removeRepoFile(tx *Transaction, name string) ([]string, error) {
+	path := filepath.Join(m.RepoDir, name)
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("stat repo file failed: %v", err)
+	}
+	file, err := LoadRepoFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, section := range file.Sections {
+		ids = append(ids, section.ID)
+	}
+	tx.Remove(name)
+	return ids, nil
+}
+
+// templateInstaller 按地址模板生成 <源名称>.repo，切换版本时直接覆盖
+type templateInstaller struct {
+	m *Manager
+}
+
+// Install 生成 .repo 文件，同时删除以别名命名的旧文件
+func (i *templateInstaller) Install(tx *Transaction, target *InstallTarget) error {
+	content, err := i.m.generateRepoContent(target.Repo, target.Releasever, target.Basearch)
+	if err != nil {
+		return err
+	}
+	if err := i.m.removeAliasFiles(tx, target.Repo); err != nil {
+		return err
+	}
+	return tx.WriteFile(target.Repo.Name+".repo", []byte(content), 0644)
+}
+
+// Uninstall 删除生成的 .repo 文件和以别名命名的旧文件
+func (i *templateInstaller) Uninstall(tx *Transaction, target *InstallTarget) ([]string, error) {
+	ids, err := i.m.removeRepoFile(tx, target.Repo.Name+".repo")
+	if err != nil {
+		return nil, err
+	}
+	if err := i.m.removeAliasFiles(tx, target.Repo); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// releaseRPMInstaller 安装 release RPM，由其提供 .repo 文件和 GPG 密钥
+type releaseRPMInstaller struct {
+	m *Manager
+}
+
+// Install 下载 release RPM 到暂存目录，提交时安装；预览时不下载，只显示 RPM 包地址。
+// 切换版本时先卸载已安装的其他版本的 release 包，事务回滚时重新安装
+func (i *releaseRPMInstaller) Install(tx *Transaction, target *InstallTarget) error {
+	repo := target.Repo
+	rpmFile, err := i.stageRPM(tx, target, repo)
+	if err != nil {
+		return err
+	}
+	if err := i.removeOtherVersions(tx, target); err != nil {
+		return err
+	}
+
+	// 尝试安装 RPM 包，如果已安装则更新；之前未安装时，事务回滚会卸载刚安装的包
+	command := tx.Run(false, i.m.rootCommand("rpm", "-Uvh", "--force", rpmFile)...)
+	if pkg := repo.ReleasePackage; pkg != "" && !i.m.packageInstalled(pkg) {
+		command.Undo = i.m.rootCommand("rpm", "-e", pkg)
+	}
+	return nil
+}
+
+// stageRPM 下载源的 release RPM 到暂存目录，返回安装时使用的路径；预览时不下载，返回 RPM 包地址
+func (i *releaseRPMInstaller) stageRPM(tx *Transaction, target *InstallTarget, repo *Repo) (string, error) {
+	rpmURL := repo.ReleaseRPMURL(target.Distro, target.Releasever, target.Basearch)
+	if rpmURL == "" {
+		return "", fmt.Errorf("repo %s has no release rpm for %s %s", repo.Name, target.Distro, target.Releasever)
+	}
+	if i.m.DryRun {
+		return rpmURL, nil
+	}
+	content, err := repo.Client().Fetch(rpmURL)
+	if err != nil {
+		return "", fmt.Errorf("download rpm failed: %v", err)
+	}
+	return tx.StageFile(path.Base(rpmURL), content)
+}
+
+// removeOtherVersions 卸载已安装的其他版本的 release 包，避免两个版本的 .repo 文件同时存在、互相覆盖；
+// 同时暂存旧版本的 release RPM，事务回滚时重新安装
+func (i *releaseRPMInstaller) removeOtherVersions(tx *Transaction, target *InstallTarget) error {
+	repo := target.Repo
+	if repo.Version == "" {
+		return nil
+	}
+	// 按源目录中的原始配置展开其他版本，target.Repo 中的地址模板已替换为当前版本
+	base, err := GetRepoByName(repo.Name)
+	if err != nil {
+		return err
+	}
+	for _, v := range base.Versions {
+		if v.Version == repo.Version {
+			continue
+		}
+		other, err := base.WithVersion(v.Version)
+		if err != nil {
+			return err
+		}
+		pkg := other.ReleasePackage
+		if pkg == "" || pkg == repo.ReleasePackage || !i.m.packageInstalled(pkg) {
+			continue
+		}
+		rpmFile, err := i.stageRPM(tx, target, other)
+		if err != nil {
+			return fmt.Errorf("%s@%s: %v", repo.Name, v.Version, err)
+		}
+		tx.Run(false, i.m.rootCommand("rpm", "-e", pkg)...).Undo = i.m.rootCommand("rpm", "-Uvh", "--force", rpmFile)
+	}
+	return nil
+}
+
+// Uninstall 卸载 release RPM，其提供的 .repo 文件随之删除；release 包未安装时不做修改
+func (i *releaseRPMInstaller) Uninstall(tx *Transaction, target *InstallTarget) ([]string, error) {
+	pkg := target.Repo.ReleasePackage
+	if pkg == "" {
+		return nil, fmt.Errorf("repo %s has no release package", target.Repo.Name)
+	}
+	if !i.m.packageInstalled(pkg) {
+		return nil, nil
+	}
+	tx.Run(false, i.m.rootCommand("rpm", "-e", pkg)...)
+	return []string{pkg}, nil
+}
+
+// packageInstalled 软件包是否已安装，无法查询时视为未安装
+func (m *Manager) packageInstalled(name string) bool {
+	if name == "" {
+		return false
+	}
+	args := m.rootCommand("rpm", "-q", "--quiet", name)
+	return exec.Command(args[0], args[1:]...).Run() == nil
+}
+
+// repoFileInstaller 下载远程 .repo 文件，文件名取地址中的文件名
+type repoFileInstaller struct {
+	m *Manager
+}
+
+// Install 下载并校验 .repo 文件，已存在同名文件时覆盖
+func (i *repoFileInstaller) Install(tx *Transaction, target *InstallTarget) error {
+	repo := target.Repo
+	rawURL := repo.RepoFileURL(target.Distro, target.Releasever, target.Basearch)
+	if rawURL == "" {
+		return fmt.Errorf("repo %s has no repo file for %s %s", repo.Name, target.Distro, target.Releasever)
+	}
+	file, err := fetchRepoFile(repo.Client(), rawURL)
+	if err != nil {
+		return err
+	}
+	return tx.WriteFile(repoFileName(repo.Name, rawURL), file.Bytes(), 0644)
+}
+
+// Uninstall 删除下载的 .repo 文件
+func (i *repoFileInstaller) Uninstall(tx *Transaction, target *InstallTarget) ([]string, error) {
+	repo := target.Repo
+	name := repoFileName(repo.Name, repo.RepoFileURL(target.Distro, target.Releasever, target.Basearch))
+	return i.m.removeRepoFile(tx, name)
+}
+
+// fetchRepoFile 下载并校验远程 .repo 文件
+func fetchRepoFile(client *utils.Client, rawURL string) (*RepoFile, error) {
+	content, err := client.Fetch(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("download repo file failed: %v", err)
+	}
+	file, err := ParseRepoFile(content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repo file %s: %v", rawURL, err)
+	}
+	if err := validateRemoteRepoFile(file); err != nil {
+		return nil, fmt.Errorf("invalid repo file %s: %v", rawURL, err)
+	}
+	return file, nil
+}
+
+// repoFileName 远程 .repo 文件安装后的文件名：地址中的文件名合法时使用该文件名，否则为 <源名称>.repo
+func repoFileName(repoName, rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		name := path.Base(u.Path)
+		if strings.HasSuffix(name, ".repo") && repoIDPattern.MatchString(strings.TrimSuffix(name, ".repo")) {
+			return name
+		}
+	}
+	return repoName + ".repo"
+}
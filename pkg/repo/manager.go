@@ -3,12 +3,12 @@ package repo
 import (
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 
+	"yuv/internal/utils"
 	"yuv/pkg/system"
 )
 
@@ -21,100 +21,54 @@ const (
 
 // Manager 源管理器
 type Manager struct {
-	RepoDir   string
-	BackupDir string
+	Root        string // 备用根目录，为空时操作当前系统
+	RepoDir     string
+	BackupDir   string
+	Command     string       // 当前执行的 yuv 命令，记录在备份清单中
+	KeepBackups int          // 保留的备份快照数量
+	SkipVerify  bool         // 写入新源前不校验元数据
+	Proxy       *utils.Proxy // 全局代理，为 nil 时使用环境变量中的代理
+	DryRun      bool         // 只预览变更，不修改系统
+	Plans       []*Plan      // DryRun 时记录的变更预览
 }
 
 // NewManager 创建源管理器实例
 func NewManager() *Manager {
 	return &Manager{
-		RepoDir:   RepoDir,
-		BackupDir: BackupDir,
+		RepoDir:     RepoDir,
+		BackupDir:   BackupDir,
+		KeepBackups: DefaultKeepBackups,
 	}
 }
 
-// Backup 备份所有源配置文件
-func (m *Manager) Backup() error {
-	// 创建备份目录
-	if err := os.MkdirAll(m.BackupDir, 0755); err != nil {
-		return fmt.Errorf("create backup directory failed: %v", err)
-	}
-
-	// 遍历源配置文件
-	files, err := ioutil.ReadDir(m.RepoDir)
-	if err != nil {
-		return fmt.Errorf("read repo directory failed: %v", err)
-	}
-
-	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
-		if !strings.HasSuffix(file.Name(), ".repo") {
-			continue
-		}
-
-		// 移动文件到备份目录
-		src := filepath.Join(m.RepoDir, file.Name())
-		dst := filepath.Join(m.BackupDir, file.Name())
-		
-		// 如果备份目录中已存在同名文件，先删除
-		if _, err := os.Stat(dst); err == nil {
-			if err := os.Remove(dst); err != nil {
-				return fmt.Errorf("remove existing backup file failed: %v", err)
-			}
-		}
-		
-		// 移动文件
-		if err := os.Rename(src, dst); err != nil {
-			return fmt.Errorf("move repo file to backup failed: %v", err)
-		}
-	}
-
-	return nil
+// SetRoot 设置备用根目录（如挂载的镜像或 chroot），源配置目录、备份目录和系统检测都位于该目录下
+func (m *Manager) SetRoot(root string) {
+	m.Root = root
+	m.RepoDir = filepath.Join(root, RepoDir)
+	m.BackupDir = filepath.Join(root, BackupDir)
 }
 
-// Restore 恢复源配置文件
-func (m *Manager) Restore() error {
-	// 检查备份目录是否存在
-	if _, err := os.Stat(m.BackupDir); os.IsNotExist(err) {
-		return fmt.Errorf("backup directory not found")
-	}
+// detector 创建检测备用根目录的系统检测器
+func (m *Manager) detector() *system.Detector {
+	return &system.Detector{Root: m.Root}
+}
 
-	// 遍历备份文件
-	files, err := ioutil.ReadDir(m.BackupDir)
-	if err != nil {
-		return fmt.Errorf("read backup directory failed: %v", err)
+// rootCommand 返回在备用根目录中执行的命令：rpm 使用 --root，yum 类命令使用 --installroot
+func (m *Manager) rootCommand(name string, args ...string) []string {
+	if m.Root == "" {
+		return append([]string{name}, args...)
 	}
-
-	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
-		if !strings.HasSuffix(file.Name(), ".repo") {
-			continue
-		}
-
-		// 复制文件到源配置目录
-		src := filepath.Join(m.BackupDir, file.Name())
-		dst := filepath.Join(m.RepoDir, file.Name())
-		content, err := ioutil.ReadFile(src)
-		if err != nil {
-			return fmt.Errorf("read backup file failed: %v", err)
-		}
-		if err := ioutil.WriteFile(dst, content, file.Mode()); err != nil {
-			return fmt.Errorf("write repo file failed: %v", err)
-		}
+	if name == "rpm" {
+		return append([]string{name, "--root", m.Root}, args...)
 	}
-
-	return nil
+	return append([]string{name, "--installroot=" + m.Root}, args...)
 }
 
-// Use 使用指定的公共镜像源
+// Use 使用指定的公共镜像源，official 恢复发行版自带的官方源
 func (m *Manager) Use(repoName, releasever, basearch string) error {
-	// 备份当前源
-	if err := m.Backup(); err != nil {
-		return fmt.Errorf("backup failed: %v", err)
+	// official 按内置模板恢复发行版自带的官方源
+	if repoName == OfficialMirror {
+		return m.UseOfficial()
 	}
 
 	// 获取源配置
@@ -123,13 +77,8 @@ func (m *Manager) Use(repoName, releasever, basearch string) error {
 		return err
 	}
 
-	// 清理当前源配置
-	if err := m.cleanRepoDir(); err != nil {
-		return fmt.Errorf("clean repo directory failed: %v", err)
-	}
-
 	// 创建系统检测器实例
-	detector := system.NewDetector()
+	detector := m.detector()
 
 	// 获取发行版名称
 	distro, err := detector.GetDistroName()
@@ -137,93 +86,54 @@ func (m *Manager) Use(repoName, releasever, basearch string) error {
 		return fmt.Errorf("get distro name failed: %v", err)
 	}
 
-	// 对于 CentOS 系统，使用阿里云的预配置 repo 文件
-	if distro == "centos" {
-		// 提取主版本号
-		parts := strings.Split(releasever, ".")
-		if len(parts) == 0 {
-			return fmt.Errorf("invalid version format: %s", releasever)
-		}
-		majorVersion := parts[0]
-
-		// 根据主版本号选择不同的 repo 文件
-		var repoURL string
-		if majorVersion == "7" {
-			repoURL = "https://mirrors.aliyun.com/repo/Centos-7.repo"
-		} else if majorVersion == "8" {
-			repoURL = "https://mirrors.aliyun.com/repo/Centos-vault-8.5.2111.repo"
-		} else {
-			// 对于其他版本，使用默认的生成方式
-			goto defaultRepo
-		}
-
-		// 下载 repo 文件
-		repoFile := filepath.Join(m.RepoDir, fmt.Sprintf("%s.repo", repoName))
-		resp, err := http.Get(repoURL)
-		if err != nil {
-			return fmt.Errorf("download repo file failed: %v", err)
-		}
-		defer resp.Body.Close()
-
-		content, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("read repo file failed: %v", err)
-		}
-
-		if err := ioutil.WriteFile(repoFile, content, 0644); err != nil {
-			return fmt.Errorf("write repo file failed: %v", err)
-		}
-
-		return nil
+	// 检查镜像源是否支持当前发行版
+	if repo.Layout(distro, releasever) == nil {
+		return fmt.Errorf("repo %s does not support %s %s", repoName, distro, releasever)
 	}
 
-defaultRepo:
 	// 检测版本是否过期
 	expired, err := detector.IsVersionExpired()
 	if err != nil {
 		return fmt.Errorf("check version expired failed: %v", err)
 	}
 
-	// 为 Rocky Linux 和 AlmaLinux 添加多个源配置
-	if distro == "rockylinux" || distro == "almalinux" {
-		// 创建 BaseOS 源
-		baseosContent, err := m.generateRepoContentForRepoType(repo, "BaseOS", releasever, basearch, expired)
-		if err != nil {
-			return err
-		}
-		baseosFile := filepath.Join(m.RepoDir, fmt.Sprintf("%s-baseos.repo", repoName))
-		if err := ioutil.WriteFile(baseosFile, []byte(baseosContent), 0644); err != nil {
-			return fmt.Errorf("write baseos repo file failed: %v", err)
-		}
+	// 切换前已启用 CRB / PowerTools 时，新生成的该仓库保持启用
+	crbEnabled, err := m.crbEnabled()
+	if err != nil {
+		return err
+	}
 
-		// 创建 AppStream 源
-		appstreamContent, err := m.generateRepoContentForRepoType(repo, "AppStream", releasever, basearch, expired)
+	// 在暂存目录中生成新的源配置，校验通过后再替换现有的 .repo 文件
+	tx, err := m.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	if err := tx.RemoveRepoFiles(); err != nil {
+		return err
+	}
+
+	// 按发行版布局为每个子仓库（BaseOS、AppStream、extras 等）生成单独的源配置，可选子仓库（CRB、PowerTools）默认禁用
+	components := repo.Components(distro, releasever)
+	optional := repo.OptionalComponents(distro, releasever)
+	for i, component := range append(append([]string{}, components...), optional...) {
+		enabled := i < len(components) || crbEnabled
+		content, err := m.generateRepoContentForRepoType(repo, component, releasever, basearch, expired, enabled)
 		if err != nil {
 			return err
 		}
-		appstreamFile := filepath.Join(m.RepoDir, fmt.Sprintf("%s-appstream.repo", repoName))
-		if err := ioutil.WriteFile(appstreamFile, []byte(appstreamContent), 0644); err != nil {
-			return fmt.Errorf("write appstream repo file failed: %v", err)
-		}
-	} else {
-		// 对于其他发行版，使用单一源配置
-		repoFile := filepath.Join(m.RepoDir, fmt.Sprintf("%s.repo", repoName))
-		content, err := m.generateRepoContent(repo, releasever, basearch)
-		if err != nil {
+		if err := tx.WriteFile(fmt.Sprintf("%s-%s.repo", repoName, strings.ToLower(component)), []byte(content), 0644); err != nil {
 			return err
 		}
-		if err := ioutil.WriteFile(repoFile, []byte(content), 0644); err != nil {
-			return fmt.Errorf("write repo file failed: %v", err)
-		}
 	}
 
-	return nil
+	return m.commit(tx)
 }
 
 // generateRepoContentForRepoType 为指定类型的源生成配置内容
-func (m *Manager) generateRepoContentForRepoType(repo *Repo, repoType, releasever, basearch string, expired bool) (string, error) {
+func (m *Manager) generateRepoContentForRepoType(repo *Repo, repoType, releasever, basearch string, expired, enabled bool) (string, error) {
 	// 创建系统检测器实例
-	detector := system.NewDetector()
+	detector := m.detector()
 
 	// 获取发行版名称
 	distro, err := detector.GetDistroName()
@@ -232,272 +142,433 @@ func (m *Manager) generateRepoContentForRepoType(repo *Repo, repoType, releaseve
 	}
 
 	// 根据过期状态选择正确的 URL
-	var url string
-	if expired && repo.VaultURL != "" {
-		url = strings.ReplaceAll(repo.VaultURL, "$distro", distro)
-		url = strings.ReplaceAll(url, "$releasever", releasever)
-		url = strings.ReplaceAll(url, "$basearch", basearch)
-		url = strings.ReplaceAll(url, "AppStream", repoType)
-	} else {
-		url = strings.ReplaceAll(repo.URL, "$distro", distro)
-		url = strings.ReplaceAll(url, "$releasever", releasever)
-		url = strings.ReplaceAll(url, "$basearch", basearch)
-		url = strings.ReplaceAll(url, "AppStream", repoType)
-	}
-
-	// 对于 CentOS 7，调整 URL 格式，移除 AppStream 部分
-	if distro == "centos" {
-		parts := strings.Split(releasever, ".")
-		if len(parts) > 0 {
-			majorVersion := parts[0]
-			if majorVersion == "7" {
-				// CentOS 7 使用 /os/x86_64/ 格式
-				url = strings.Replace(url, "/AppStream/x86_64/os/", "/os/x86_64/", -1)
-				url = strings.Replace(url, "/BaseOS/x86_64/os/", "/os/x86_64/", -1)
-			}
-		}
-	}
+	urls := repo.ComponentURLs(distro, releasever, basearch, repoType, expired)
 
 	// 获取 GPG 密钥 URL
-	gpgKey := strings.ReplaceAll(repo.GPGKey, "$distro", distro)
-	gpgKey = strings.ReplaceAll(gpgKey, "$releasever", releasever)
-	gpgKey = strings.ReplaceAll(gpgKey, "$basearch", basearch)
+	gpgKey := repo.GetGPGKeyURL(distro, releasever, basearch)
 
 	// 生成源配置内容
-	return fmt.Sprintf(`[%s-%s]
-name=%s %s Repository
-baseurl=%s
-enabled=1
-gpgcheck=1
-gpgkey=%s
-priority=%d
-`,
-		repo.Name, repoType, repo.Name, repoType, url, gpgKey, repo.Priority), nil
+	id := fmt.Sprintf("%s-%s", repo.Name, repoType)
+	return renderRepo(id, fmt.Sprintf("%s %s Repository", repo.Name, repoType), urls, enabled, gpgKey, repo.Priority, m.repoProxy(repo, urls)), nil
 }
 
-// Add 添加指定的源
+// Add 添加指定的源，按源目录中声明的安装方式（模板、release RPM、远程 .repo 文件、Copr）安装；
+// copr:owner/project 直接添加 Copr 项目
 func (m *Manager) Add(repoName, releasever, basearch string) error {
 	// 获取源配置
-	repo, err := GetRepoByName(repoName)
+	repo, err := lookupRepo(repoName)
+	if err != nil {
+		return err
+	}
+	distro, err := m.detector().GetDistroName()
+	if err != nil {
+		return fmt.Errorf("get distro name failed: %v", err)
+	}
+	target := &InstallTarget{Repo: repo, Distro: distro, Releasever: releasever, Basearch: basearch}
+	installer, err := m.Installer(target)
 	if err != nil {
 		return err
 	}
 
-	// 对 MySQL 仓库使用 RPM 包安装的方式
-	if strings.Contains(repoName, "mysql") {
-		// 提取主版本号和小版本号
-		var rpmURL string
-		parts := strings.Split(releasever, ".")
-		if len(parts) > 0 {
-			elVersion := parts[0]
-			// 默认为9，如果有小版本号则使用小版本号
-			subVersion := "9"
-			if len(parts) > 1 {
-				subVersion = parts[1]
-			}
-			// 构建 RPM 包 URL
-			if strings.Contains(repoName, "57") {
-				rpmURL = fmt.Sprintf("https://repo.mysql.com/mysql57-community-release-el%s-%s.noarch.rpm", elVersion, subVersion)
-			} else {
-				rpmURL = fmt.Sprintf("https://repo.mysql.com/mysql80-community-release-el%s-%s.noarch.rpm", elVersion, subVersion)
-			}
-		} else {
-			return fmt.Errorf("invalid releasever format: %s", releasever)
-		}
-
-		// 禁用 MySQL 模块
-		cmd := exec.Command("yum", "module", "-y", "disable", "mysql")
-		if err := cmd.Run(); err != nil {
-			// 忽略错误，因为在某些系统上可能没有 mysql 模块
-		}
+	tx, err := m.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
 
-		// 下载并安装 RPM 包
-		rpmFile := filepath.Join("/tmp", fmt.Sprintf("%s-release.rpm", repoName))
-		cmd = exec.Command("curl", "-o", rpmFile, rpmURL)
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("download rpm failed: %v", err)
-		}
+	// 先调整模块流，避免模块过滤屏蔽新源中的同名软件包
+	if err := m.moduleCommands(tx, repo); err != nil {
+		return err
+	}
 
-		// 尝试安装 RPM 包，如果已安装则更新
-		cmd = exec.Command("rpm", "-Uvh", "--force", rpmFile)
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("install rpm failed: %v", err)
+	// 依赖 CRB / PowerTools 的源（如 EPEL、RPM Fusion）同时启用该仓库
+	if repo.NeedsCRB {
+		if err := m.enableCRB(tx); err != nil {
+			return err
 		}
+	}
 
-		// 清理临时文件
-		os.Remove(rpmFile)
+	if err := installer.Install(tx, target); err != nil {
+		return err
+	}
+	return m.commit(tx)
+}
 
+// moduleCommands 把源目录中为该源声明的模块流调整加入事务，没有模块功能的系统（EL7）跳过
+func (m *Manager) moduleCommands(tx *Transaction, repo *Repo) error {
+	if repo.Modules == nil {
+		return nil
+	}
+	distro, err := m.detector().Detect()
+	if err != nil {
+		return fmt.Errorf("detect system failed: %v", err)
+	}
+	if major, _ := strconv.Atoi(strings.Split(distro.Version, ".")[0]); distro.Name != "fedora" && major < 8 {
 		return nil
 	}
 
-	// 对 Docker 仓库使用 yum-config-manager 安装方式（除了 AlmaLinux 和 Fedora）
-	if repoName == "docker" {
-		// 创建系统检测器实例
-		detector := system.NewDetector()
-		// 获取发行版名称
-		distro, err := detector.GetDistroName()
-		if err != nil {
-			return fmt.Errorf("get distro name failed: %v", err)
+	for _, action := range []struct {
+		name  string
+		specs []string
+	}{
+		{"reset", repo.Modules.Reset},
+		{"disable", repo.Modules.Disable},
+		{"enable", repo.Modules.Enable},
+	} {
+		if len(action.specs) > 0 {
+			tx.Run(false, m.rootCommand("dnf", append([]string{"module", "-y", action.name}, action.specs...)...)...)
 		}
+	}
+	return nil
+}
 
-		// 排除 AlmaLinux 和 Fedora
-		if distro != "almalinux" && distro != "fedora" {
-			// 检查并安装 yum-utils
-			_, err := exec.LookPath("yum-config-manager")
+// Remove 删除匹配的源，支持节 ID、通配符或文件名，返回被删除的源 ID；
+// 预置的第三方源按添加时的安装方式撤销（如卸载 release RPM），没有通过该方式添加时再按节 ID 匹配
+func (m *Manager) Remove(patterns ...string) ([]string, error) {
+	tx, err := m.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+
+	var ids, rest []string
+	for _, pattern := range patterns {
+		var removed []string
+		for _, repo := range m.catalogRepos(pattern) {
+			target, err := m.installTarget(repo)
 			if err != nil {
-				// 安装 yum-utils
-				cmd := exec.Command("yum", "install", "-y", "yum-utils")
-				if err := cmd.Run(); err != nil {
-					return fmt.Errorf("install yum-utils failed: %v", err)
-				}
+				return nil, err
 			}
-
-			// 使用 yum-config-manager 添加阿里云 Docker 源
-			cmd := exec.Command("yum-config-manager", "--add-repo", "https://mirrors.aliyun.com/docker-ce/linux/centos/docker-ce.repo")
-			if err := cmd.Run(); err != nil {
-				return fmt.Errorf("add docker repo failed: %v", err)
+			installer, err := m.Installer(target)
+			if err != nil {
+				return nil, err
 			}
-
-			return nil
+			if removed, err = installer.Uninstall(tx, target); err != nil {
+				return nil, err
+			}
+			if len(removed) > 0 {
+				break
+			}
+		}
+		// 没有通过安装方式添加时按节 ID 或通配符匹配，如 release RPM 创建的 epel、mysql80-community
+		if len(removed) == 0 {
+			rest = append(rest, pattern)
+			continue
 		}
+		ids = append(ids, removed...)
 	}
 
-	// 对 Kubernetes 仓库使用阿里云源（除了 AlmaLinux 和 Fedora）
-	if repoName == "kubernetes" {
-		// 创建系统检测器实例
-		detector := system.NewDetector()
-		// 获取发行版名称
-		distro, err := detector.GetDistroName()
+	if len(rest) > 0 {
+		matches, err := m.findAll(rest)
 		if err != nil {
-			return fmt.Errorf("get distro name failed: %v", err)
+			return nil, err
+		}
+		touched := map[string]*RepoFile{}
+		for _, match := range matches {
+			match.File.RemoveSection(match.Section.ID)
+			touched[match.Path] = match.File
+			ids = append(ids, match.Section.ID)
+		}
+		if err := m.stageRepoFiles(tx, touched); err != nil {
+			return nil, err
 		}
+	}
 
-		// 排除 AlmaLinux 和 Fedora
-		if distro != "almalinux" && distro != "fedora" {
-			// 构建 Kubernetes 源配置
-			repoFile := filepath.Join(m.RepoDir, "kubernetes.repo")
-			repoContent := `[kubernetes]
-name=Kubernetes
-baseurl=https://mirrors.aliyun.com/kubernetes-new/core/stable/v1.28/rpm/
-enabled=1
-gpgcheck=1
-gpgkey=https://mirrors.aliyun.com/kubernetes-new/core/stable/v1.28/rpm/repodata/repomd.xml.key
-`
-
-			// 写入配置文件
-			if err := ioutil.WriteFile(repoFile, []byte(repoContent), 0644); err != nil {
-				return fmt.Errorf("write kubernetes repo file failed: %v", err)
-			}
+	return ids, m.apply(tx, false)
+}
 
+// catalogRepos 参数是预置的第三方源名称（可带 @version）或 copr:owner/project 时返回其配置，
+// 未指定版本时返回源的所有版本，删除时撤销已安装的版本；通配符和公共镜像源返回 nil
+func (m *Manager) catalogRepos(pattern string) []*Repo {
+	if strings.ContainsAny(pattern, "*?[") {
+		return nil
+	}
+	if strings.HasPrefix(pattern, CoprScheme) {
+		repo, err := coprRepo(pattern)
+		if err != nil {
 			return nil
 		}
+		return []*Repo{repo}
 	}
-
-	// 其他仓库使用传统方式
-	repoFile := filepath.Join(m.RepoDir, fmt.Sprintf("%s.repo", repoName))
-	content, err := m.generateRepoContent(repo, releasever, basearch)
+	catalog, err := DefaultCatalog()
 	if err != nil {
-		return err
+		return nil
+	}
+	repo, version, err := catalog.resolve(pattern)
+	if err != nil || repo.Type == TypePublic {
+		return nil
+	}
+	versions := []string{version}
+	if version == "" && len(repo.Versions) > 0 {
+		versions = repo.VersionNames()
 	}
-	if err := ioutil.WriteFile(repoFile, []byte(content), 0644); err != nil {
-		return fmt.Errorf("write repo file failed: %v", err)
+	var repos []*Repo
+	for _, v := range versions {
+		versioned, err := repo.WithVersion(v)
+		if err != nil {
+			return nil
+		}
+		repos = append(repos, versioned)
 	}
+	return repos
+}
 
-	return nil
+// lookupRepo 根据名称获取源配置，copr:owner/project 生成临时的 Copr 源
+func lookupRepo(name string) (*Repo, error) {
+	if strings.HasPrefix(name, CoprScheme) {
+		return coprRepo(name)
+	}
+	return GetRepoByName(name)
 }
 
-// Remove 删除指定的源
-func (m *Manager) Remove(repoName string) error {
-	repoFile := filepath.Join(m.RepoDir, fmt.Sprintf("%s.repo", repoName))
-	if err := os.Remove(repoFile); err != nil {
-		if os.IsNotExist(err) {
-			return fmt.Errorf("repo %s not found", repoName)
-		}
-		return fmt.Errorf("remove repo file failed: %v", err)
+// RepoInfo 源的结构化信息，对应 .repo 文件中的一个节
+type RepoInfo struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	File       string   `json:"file"`
+	Enabled    bool     `json:"enabled"`
+	BaseURL    []string `json:"baseurl,omitempty"`
+	Mirrorlist string   `json:"mirrorlist,omitempty"`
+	Metalink   string   `json:"metalink,omitempty"`
+	GPGCheck   bool     `json:"gpgcheck"`
+	Priority   int      `json:"priority"`
+	Proxy      string   `json:"proxy,omitempty"` // 源单独设置的代理，_none_ 表示不使用代理
+	Copr       string   `json:"copr,omitempty"`  // yuv 添加的 Copr 项目 owner/project
+}
+
+// DefaultPriority dnf 未设置 priority 时的默认优先级
+const DefaultPriority = 99
+
+// URL 返回源的主要地址，依次取 baseurl、mirrorlist、metalink
+func (r *RepoInfo) URL() string {
+	switch {
+	case len(r.BaseURL) > 0:
+		return r.BaseURL[0]
+	case r.Mirrorlist != "":
+		return r.Mirrorlist
+	default:
+		return r.Metalink
 	}
+}
 
-	return nil
+// newRepoInfo 从节中提取源信息
+func newRepoInfo(path string, s *Section) *RepoInfo {
+	info := &RepoInfo{
+		ID:         s.ID,
+		Name:       s.Value("name"),
+		File:       path,
+		Enabled:    s.Enabled(),
+		BaseURL:    s.Values("baseurl"),
+		Mirrorlist: s.Value("mirrorlist"),
+		Metalink:   s.Value("metalink"),
+		Priority:   DefaultPriority,
+		Proxy:      (&utils.Proxy{URL: s.Value("proxy")}).Redacted(),
+	}
+	if gpgcheck, err := s.Bool("gpgcheck", false); err == nil {
+		info.GPGCheck = gpgcheck
+	}
+	if priority, err := strconv.Atoi(s.Value("priority")); err == nil {
+		info.Priority = priority
+	}
+	return info
 }
 
-// List 列出所有源
-func (m *Manager) List() ([]string, error) {
-	files, err := ioutil.ReadDir(m.RepoDir)
+// List 列出所有源，每个节一条记录，按文件名和节顺序排列
+func (m *Manager) List() ([]*RepoInfo, error) {
+	files, err := m.loadRepoFiles()
 	if err != nil {
-		return nil, fmt.Errorf("read repo directory failed: %v", err)
+		return nil, err
 	}
 
-	var repos []string
+	var repos []*RepoInfo
 	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
-		if strings.HasSuffix(file.Name(), ".repo") {
-			repos = append(repos, strings.TrimSuffix(file.Name(), ".repo"))
+		copr := taggedCoprProject(file.File)
+		for _, section := range file.File.Sections {
+			info := newRepoInfo(file.Path, section)
+			info.Copr = copr
+			repos = append(repos, info)
 		}
 	}
 
 	return repos, nil
 }
 
-// Enable 启用指定的源
-func (m *Manager) Enable(repoName string) error {
-	return m.setRepoEnabled(repoName, true)
+// Enable 启用匹配的源，返回被修改的源 ID
+func (m *Manager) Enable(patterns ...string) ([]string, error) {
+	return m.setRepoEnabled(patterns, true)
 }
 
-// Disable 禁用指定的源
-func (m *Manager) Disable(repoName string) error {
-	return m.setRepoEnabled(repoName, false)
+// Disable 禁用匹配的源，返回被修改的源 ID
+func (m *Manager) Disable(patterns ...string) ([]string, error) {
+	return m.setRepoEnabled(patterns, false)
 }
 
-// setRepoEnabled 设置源的启用状态
-func (m *Manager) setRepoEnabled(repoName string, enabled bool) error {
-	repoFile := filepath.Join(m.RepoDir, fmt.Sprintf("%s.repo", repoName))
-	content, err := ioutil.ReadFile(repoFile)
+// setRepoEnabled 设置源的启用状态，只修改匹配的节
+func (m *Manager) setRepoEnabled(patterns []string, enabled bool) ([]string, error) {
+	matches, err := m.findAll(patterns)
 	if err != nil {
-		return fmt.Errorf("read repo file failed: %v", err)
+		return nil, err
 	}
 
-	// 替换 enabled 配置
-	newContent := string(content)
-	if enabled {
-		newContent = strings.Replace(newContent, "enabled=0", "enabled=1", -1)
-	} else {
-		newContent = strings.Replace(newContent, "enabled=1", "enabled=0", -1)
+	var ids []string
+	touched := map[string]*RepoFile{}
+	for _, match := range matches {
+		match.Section.SetBool("enabled", enabled)
+		touched[match.Path] = match.File
+		ids = append(ids, match.Section.ID)
 	}
 
-	// 写入文件
-	if err := ioutil.WriteFile(repoFile, []byte(newContent), 0644); err != nil {
-		return fmt.Errorf("write repo file failed: %v", err)
+	if err := m.saveRepoFiles(touched); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// saveRepoFiles 通过事务写回修改过的源文件，文件中已没有任何节时删除整个文件
+func (m *Manager) saveRepoFiles(touched map[string]*RepoFile) error {
+	tx, err := m.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	if err := m.stageRepoFiles(tx, touched); err != nil {
+		return err
 	}
+	return m.apply(tx, false)
+}
 
+// stageRepoFiles 将修改过的源文件加入事务，保留原有权限
+func (m *Manager) stageRepoFiles(tx *Transaction, touched map[string]*RepoFile) error {
+	for path, file := range touched {
+		name := filepath.Base(path)
+		if len(file.Sections) == 0 {
+			tx.Remove(name)
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("stat repo file failed: %v", err)
+		}
+		if err := tx.WriteFile(name, file.Bytes(), info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// cleanRepoDir 清理源配置目录
-func (m *Manager) cleanRepoDir() error {
+// loadedFile 已解析的源配置文件
+type loadedFile struct {
+	Path string
+	File *RepoFile
+}
+
+// SectionMatch 匹配到的源节及其所在文件
+type SectionMatch struct {
+	Path    string    // .repo 文件路径
+	File    *RepoFile // 解析后的文件
+	Section *Section  // 匹配到的节
+}
+
+// loadRepoFiles 解析源配置目录下的所有 .repo 文件，按文件名排序
+func (m *Manager) loadRepoFiles() ([]*loadedFile, error) {
 	files, err := ioutil.ReadDir(m.RepoDir)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("read repo directory failed: %v", err)
 	}
 
+	var loaded []*loadedFile
 	for _, file := range files {
-		if file.IsDir() {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".repo") {
 			continue
 		}
-		if strings.HasSuffix(file.Name(), ".repo") {
-			if err := os.Remove(filepath.Join(m.RepoDir, file.Name())); err != nil {
-				return err
+		path := filepath.Join(m.RepoDir, file.Name())
+		repoFile, err := LoadRepoFile(path)
+		if err != nil {
+			return nil, err
+		}
+		loaded = append(loaded, &loadedFile{Path: path, File: repoFile})
+	}
+
+	return loaded, nil
+}
+
+// FindSections 在整个源配置目录中查找匹配的节
+// pattern 可以是节 ID（如 baseos）、通配符（如 aliyun-*），
+// 也兼容旧用法的文件名（如 aliyun-baseos，匹配该文件中的所有节）
+func (m *Manager) FindSections(pattern string) ([]*SectionMatch, error) {
+	files, err := m.loadRepoFiles()
+	if err != nil {
+		return nil, err
+	}
+	return findSections(files, pattern)
+}
+
+// findAll 在同一份已解析的文件中依次查找多个模式，重复匹配的节只返回一次
+func (m *Manager) findAll(patterns []string) ([]*SectionMatch, error) {
+	files, err := m.loadRepoFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var all []*SectionMatch
+	seen := map[*Section]bool{}
+	for _, pattern := range patterns {
+		matches, err := findSections(files, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			if !seen[match.Section] {
+				seen[match.Section] = true
+				all = append(all, match)
 			}
 		}
 	}
+	return all, nil
+}
 
-	return nil
+// findSections 在已解析的文件中查找匹配的节
+func findSections(files []*loadedFile, pattern string) ([]*SectionMatch, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid repo pattern %q: %v", pattern, err)
+	}
+
+	// 依次尝试：区分大小写匹配、不区分大小写匹配、按文件名匹配
+	matchers := []func(f *loadedFile, s *Section) bool{
+		func(f *loadedFile, s *Section) bool {
+			ok, _ := filepath.Match(pattern, s.ID)
+			return ok
+		},
+		func(f *loadedFile, s *Section) bool {
+			ok, _ := filepath.Match(strings.ToLower(pattern), strings.ToLower(s.ID))
+			return ok
+		},
+		func(f *loadedFile, s *Section) bool {
+			ok, _ := filepath.Match(pattern, strings.TrimSuffix(filepath.Base(f.Path), ".repo"))
+			return ok
+		},
+	}
+	for _, matcher := range matchers {
+		var matches []*SectionMatch
+		for _, file := range files {
+			for _, section := range file.File.Sections {
+				if matcher(file, section) {
+					matches = append(matches, &SectionMatch{Path: file.Path, File: file.File, Section: section})
+				}
+			}
+		}
+		if len(matches) > 0 {
+			return matches, nil
+		}
+	}
+
+	return nil, fmt.Errorf("repo %s not found", pattern)
 }
 
 // generateRepoContent 生成源配置文件内容
 func (m *Manager) generateRepoContent(repo *Repo, releasever, basearch string) (string, error) {
 	// 创建系统检测器实例
-	detector := system.NewDetector()
+	detector := m.detector()
 
 	// 获取发行版名称
 	distro, err := detector.GetDistroName()
@@ -511,58 +582,38 @@ func (m *Manager) generateRepoContent(repo *Repo, releasever, basearch string) (
 		return "", fmt.Errorf("check version expired failed: %v", err)
 	}
 
-	// 对 MySQL 仓库特殊处理，只使用主版本号
-	mysqlReleasever := releasever
-	if strings.Contains(repo.Name, "mysql") {
-		// 提取主版本号（如从 8.9 提取 8）
-		parts := strings.Split(releasever, ".")
-		if len(parts) > 0 {
-			mysqlReleasever = parts[0]
-		}
-	}
-
-	// 根据过期状态选择正确的 URL
-	var url string
-	if expired && repo.VaultURL != "" {
-		// 对于非 MySQL 仓库，使用完整的版本号，以支持 CentOS 7.9.2009 这样的格式
-		if strings.Contains(repo.Name, "mysql") {
-			url = repo.GetVaultURL(distro, mysqlReleasever, basearch)
-		} else {
-			url = repo.GetVaultURL(distro, releasever, basearch)
-		}
-	} else {
-		// 对于非 MySQL 仓库，使用完整的版本号
-		if strings.Contains(repo.Name, "mysql") {
-			url = repo.ReplaceVariables(distro, mysqlReleasever, basearch)
-		} else {
-			url = repo.ReplaceVariables(distro, releasever, basearch)
-		}
-	}
-	
-	// 对于 CentOS 7，调整 URL 格式，移除 AppStream 部分
-	if distro == "centos" {
-		parts := strings.Split(releasever, ".")
-		if len(parts) > 0 {
-			majorVersion := parts[0]
-			if majorVersion == "7" {
-				// CentOS 7 使用 /os/x86_64/ 格式
-				url = strings.Replace(url, "/AppStream/x86_64/os/", "/os/x86_64/", -1)
-			}
-		}
-	}
+	// 根据过期状态选择正确的 URL，只使用主版本号的源在模板中使用 $releasever_major
+	urls := repo.URLs(distro, releasever, basearch, expired)
 
 	// 获取 GPG 密钥 URL
 	gpgKey := repo.GetGPGKeyURL(distro, releasever, basearch)
 
-	return fmt.Sprintf(`[%s]
-name=%s Repository
-baseurl=%s
-enabled=%d
-gpgcheck=1
-gpgkey=%s
-priority=%d
-`,
-		repo.Name, repo.Name, url, boolToInt(repo.Enabled), gpgKey, repo.Priority), nil
+	// 声明了版本的源在名称中注明版本
+	title := repo.Name + " Repository"
+	if repo.Version != "" {
+		title = fmt.Sprintf("%s %s Repository", repo.Name, repo.Version)
+	}
+
+	return renderRepo(repo.Name, title, urls, repo.Enabled, gpgKey, repo.Priority, m.repoProxy(repo, urls)), nil
+}
+
+// renderRepo 生成单个源的 .repo 文件内容，多个 baseurl 写成多行，mirrorlist / metalink 写在 baseurl 之后，
+// priority 为 0 时不写 priority，使用 dnf 默认的 99；proxy 为 nil 时不写代理选项，沿用主配置文件中的全局代理
+func renderRepo(id, name string, urls *RepoURLs, enabled bool, gpgKey string, priority int, proxy *utils.Proxy) string {
+	file := NewRepoFile()
+	section := file.AddSection(id)
+	section.Set("name", name)
+	urls.Apply(section)
+	section.Set("enabled", strconv.Itoa(boolToInt(enabled)))
+	section.Set("gpgcheck", "1")
+	section.Set("gpgkey", gpgKey)
+	if priority > 0 {
+		section.Set("priority", strconv.Itoa(priority))
+	}
+	if proxy != nil {
+		setSectionProxy(section, proxy)
+	}
+	return string(file.Bytes())
 }
 
 // boolToInt 将布尔值转换为整数
@@ -0,0 +1,106 @@
+package repo
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"strings"
+
+	"yuv/pkg/system"
+)
+
+// OfficialMirror yuv repo use 恢复发行版官方源时使用的名称
+const OfficialMirror = "official"
+
+//go:embed official
+var officialTemplates embed.FS
+
+// officialDir 返回发行版官方源模板所在的目录：CentOS 7 为 centos7，CentOS Stream 为 centos-stream<主版本号>，
+// Rocky、Alma 为 <发行版><主版本号>，Fedora 各版本共用 fedora；没有模板时返回空字符串
+func officialDir(distro *system.Distro) string {
+	major, minor, _ := strings.Cut(distro.Version, ".")
+	switch distro.Name {
+	case "fedora":
+		return "fedora"
+	case "centos":
+		if major == "7" {
+			return "centos7"
+		}
+		// CentOS Linux 8 已停止维护，只有 CentOS Stream 的版本号不带小版本
+		if minor == "" {
+			return "centos-stream" + major
+		}
+		return ""
+	}
+	return distro.Name + major
+}
+
+// OfficialRepoFiles 返回发行版自带的官方 .repo 文件，文件名 -> 内容，
+// 地址中保留 $releasever、$basearch 等变量，与发行版 release 包安装的文件一致
+func OfficialRepoFiles(distro *system.Distro) (map[string][]byte, error) {
+	dir := officialDir(distro)
+	if dir == "" {
+		return nil, fmt.Errorf("no official repo templates for %s %s", distro.Name, distro.Version)
+	}
+	entries, err := officialTemplates.ReadDir(path.Join("official", dir))
+	if err != nil {
+		return nil, fmt.Errorf("no official repo templates for %s %s", distro.Name, distro.Version)
+	}
+	files := map[string][]byte{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".repo") {
+			continue
+		}
+		content, err := officialTemplates.ReadFile(path.Join("official", dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read official repo template failed: %v", err)
+		}
+		files[entry.Name()] = content
+	}
+	return files, nil
+}
+
+// UseOfficial 删除现有的 .repo 文件，按内置模板重新生成发行版默认的官方源配置；
+// 切换前已启用 CRB / PowerTools 时，新生成的该仓库保持启用
+func (m *Manager) UseOfficial() error {
+	distro, err := m.detector().Detect()
+	if err != nil {
+		return fmt.Errorf("detect system failed: %v", err)
+	}
+	files, err := OfficialRepoFiles(distro)
+	if err != nil {
+		return err
+	}
+	crbEnabled, err := m.crbEnabled()
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	if err := tx.RemoveRepoFiles(); err != nil {
+		return err
+	}
+
+	for name, content := range files {
+		if crbEnabled {
+			file, err := ParseRepoFile(content)
+			if err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			for _, section := range file.Sections {
+				if isCRBRepo(section.ID) {
+					section.SetBool("enabled", true)
+				}
+			}
+			content = file.Bytes()
+		}
+		if err := tx.WriteFile(name, content, 0644); err != nil {
+			return err
+		}
+	}
+	return m.commit(tx)
+}
@@ -0,0 +1,259 @@
+package repo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"yuv/internal/utils"
+)
+
+const (
+	// ProxyFile yuv 全局代理配置文件
+	ProxyFile = "/etc/yuv/proxy.yaml"
+	// ProxyNone dnf/yum 配置中表示不使用代理的取值
+	ProxyNone = "_none_"
+)
+
+// MainConfFiles dnf/yum 主配置文件，使用第一个存在的文件
+var MainConfFiles = []string{"/etc/dnf/dnf.conf", "/etc/yum.conf"}
+
+// proxyKeys dnf/yum 配置中的代理选项
+var proxyKeys = []string{"proxy", "proxy_username", "proxy_password"}
+
+// LoadProxy 读取全局代理配置，并应用到 yuv 自身发起的所有 HTTP 请求；未配置时使用环境变量中的代理
+func (m *Manager) LoadProxy() error {
+	content, err := ioutil.ReadFile(filepath.Join(m.Root, ProxyFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("read proxy config failed: %v", err)
+	}
+	proxy := &utils.Proxy{}
+	if err := yaml.Unmarshal(content, proxy); err != nil {
+		return fmt.Errorf("parse proxy config failed: %v", err)
+	}
+	if err := proxy.Validate(); err != nil {
+		return fmt.Errorf("invalid proxy config: %v", err)
+	}
+	m.Proxy = proxy
+	utils.SetProxy(proxy)
+	return nil
+}
+
+// SetProxy 保存全局代理配置，并在同一事务中写入 dnf/yum 主配置文件的 [main] 节。
+// dnf 没有 no_proxy 选项，之后生成的源全部地址都在 no_proxy 中时写入 proxy=_none_
+func (m *Manager) SetProxy(proxy *utils.Proxy) error {
+	if proxy.URL == "" {
+		return fmt.Errorf("proxy url is required")
+	}
+	if err := proxy.Validate(); err != nil {
+		return err
+	}
+	content, err := yaml.Marshal(proxy)
+	if err != nil {
+		return fmt.Errorf("encode proxy config failed: %v", err)
+	}
+
+	tx, err := m.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	// 配置中可能包含代理密码，只允许 root 读取
+	tx.WriteConfigFile(ProxyFile, content, 0600)
+	if err := m.updateMainConf(tx, func(main *Section) { setSectionProxy(main, proxy) }); err != nil {
+		return err
+	}
+	if err := m.apply(tx, false); err != nil {
+		return err
+	}
+	m.Proxy = proxy
+	utils.SetProxy(proxy)
+	return nil
+}
+
+// UnsetProxy 删除全局代理配置，并在同一事务中从 dnf/yum 主配置文件移除代理选项
+func (m *Manager) UnsetProxy() error {
+	tx, err := m.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	tx.RemoveConfigFile(ProxyFile)
+	if err := m.updateMainConf(tx, func(main *Section) { clearSectionProxy(main) }); err != nil {
+		return err
+	}
+	if err := m.apply(tx, false); err != nil {
+		return err
+	}
+	m.Proxy = nil
+	utils.SetProxy(nil)
+	return nil
+}
+
+// SetRepoProxy 为匹配的源设置代理，URL 为空时写入 _none_ 使其不使用代理，proxy 为 nil 时删除代理选项，
+// 返回被修改的源 ID
+func (m *Manager) SetRepoProxy(proxy *utils.Proxy, patterns ...string) ([]string, error) {
+	if proxy != nil {
+		if err := proxy.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	matches, err := m.findAll(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	touched := map[string]*RepoFile{}
+	for _, match := range matches {
+		if proxy == nil {
+			clearSectionProxy(match.Section)
+		} else {
+			setSectionProxy(match.Section, proxy)
+		}
+		touched[match.Path] = match.File
+		ids = append(ids, match.Section.ID)
+	}
+
+	if err := m.saveRepoFiles(touched); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// repoProxy 返回生成的源需要写入的代理：优先使用源目录中为该源配置的代理；
+// 源的所有地址都在全局 no_proxy 中时不使用代理；否则返回 nil，沿用主配置文件中的全局代理
+func (m *Manager) repoProxy(repo *Repo, urls *RepoURLs) *utils.Proxy {
+	if repo.Proxy != nil {
+		return repo.Proxy
+	}
+	if m.Proxy == nil || len(m.Proxy.NoProxy) == 0 {
+		return nil
+	}
+	all := append(append([]string{}, urls.BaseURLs...), urls.Mirrorlist, urls.Metalink)
+	var hosts int
+	for _, raw := range all {
+		if raw == "" {
+			continue
+		}
+		u, err := url.Parse(raw)
+		if err != nil || !m.Proxy.Bypass(u.Hostname()) {
+			return nil
+		}
+		hosts++
+	}
+	if hosts == 0 {
+		return nil
+	}
+	return &utils.Proxy{}
+}
+
+// Client 返回访问该源使用的 HTTP 客户端，源目录中没有为其配置代理时使用全局代理
+func (r *Repo) Client() *utils.Client {
+	if r.Proxy == nil {
+		return utils.DefaultClient
+	}
+	return utils.NewClient(r.Proxy)
+}
+
+// setSectionProxy 在节中写入代理选项，URL 为空时写入 _none_ 表示不使用代理
+func setSectionProxy(section *Section, proxy *utils.Proxy) {
+	clearSectionProxy(section)
+	if proxy.URL == "" {
+		section.Set("proxy", ProxyNone)
+		return
+	}
+	section.Set("proxy", proxy.URL)
+	if proxy.Username != "" {
+		section.Set("proxy_username", proxy.Username)
+	}
+	if proxy.Password != "" {
+		section.Set("proxy_password", proxy.Password)
+	}
+}
+
+// clearSectionProxy 删除节中的代理选项
+func clearSectionProxy(section *Section) {
+	for _, key := range proxyKeys {
+		section.Delete(key)
+	}
+}
+
+// sectionClient 返回访问源使用的 HTTP 客户端，与 dnf 一致：源中设置了 proxy 时使用源的代理，否则使用全局代理
+func sectionClient(section *Section, expand func(string) string) *utils.Client {
+	value, ok := section.Get("proxy")
+	if !ok {
+		return utils.DefaultClient
+	}
+	value = expand(value)
+	if value == "" || value == ProxyNone {
+		return utils.NewClient(&utils.Proxy{})
+	}
+	return utils.NewClient(&utils.Proxy{
+		URL:      value,
+		Username: expand(section.Value("proxy_username")),
+		Password: expand(section.Value("proxy_password")),
+	})
+}
+
+// mainConfFile 返回 dnf/yum 主配置文件的路径（相对于备用根目录），
+// 都不存在时 CentOS 7 使用 /etc/yum.conf，其他系统使用 /etc/dnf/dnf.conf
+func (m *Manager) mainConfFile() (string, error) {
+	for _, name := range MainConfFiles {
+		if _, err := os.Stat(filepath.Join(m.Root, name)); err == nil {
+			return name, nil
+		}
+	}
+	distro, err := m.detector().Detect()
+	if err != nil {
+		return "", fmt.Errorf("detect system failed: %v", err)
+	}
+	if distro.Name != "fedora" && strings.Split(distro.Version, ".")[0] == "7" {
+		return "/etc/yum.conf", nil
+	}
+	return "/etc/dnf/dnf.conf", nil
+}
+
+// updateMainConf 在事务中修改 dnf/yum 主配置文件的 [main] 节，保留其他内容和注释
+func (m *Manager) updateMainConf(tx *Transaction, update func(main *Section)) error {
+	name, err := m.mainConfFile()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(m.Root, name)
+
+	file := NewRepoFile()
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		if file, err = LoadRepoFile(path); err != nil {
+			return fmt.Errorf("parse %s failed: %v", name, err)
+		}
+		mode = info.Mode().Perm()
+	}
+	main := file.Section("main")
+	if main == nil {
+		main = file.AddSection("main")
+	}
+	update(main)
+	tx.WriteConfigFile(name, file.Bytes(), mode)
+	return nil
+}
+
+// credentialMode 内容中写有 proxy_password 时去掉组和其他用户的权限，避免本地用户读取代理密码
+func credentialMode(content []byte, mode os.FileMode) os.FileMode {
+	for _, line := range strings.Split(string(content), "\n") {
+		key, _, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if ok && strings.TrimSpace(key) == "proxy_password" {
+			return mode &^ 0077
+		}
+	}
+	return mode
+}
@@ -0,0 +1,113 @@
+package repo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"yuv/internal/utils"
+)
+
+// proxyRoot 创建带有源配置目录和 dnf.conf 的备用根目录
+func proxyRoot(t *testing.T) *Manager {
+	t.Helper()
+	root := t.TempDir()
+	for _, dir := range []string{RepoDir, "/etc/dnf", "/etc/yuv"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "/etc/dnf/dnf.conf"), []byte("[main]\ngpgcheck=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { utils.SetProxy(nil) })
+	m := NewManager()
+	m.SetRoot(root)
+	return m
+}
+
+func TestSetProxy(t *testing.T) {
+	m := proxyRoot(t)
+	if err := m.SetProxy(&utils.Proxy{URL: "http://proxy.example.com:3128", Username: "build", Password: "secret"}); err != nil {
+		t.Fatalf("SetProxy() error = %v", err)
+	}
+	want := map[string]string{
+		"/etc/dnf/dnf.conf":   "[main]\ngpgcheck=1\nproxy=http://proxy.example.com:3128\nproxy_username=build\nproxy_password=secret\n",
+		"/etc/yuv/proxy.yaml": "url: http://proxy.example.com:3128\nusername: build\npassword: secret\n",
+	}
+	for name, content := range want {
+		path := filepath.Join(m.Root, name)
+		got, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != content {
+			t.Errorf("%s = %q, want %q", name, got, content)
+		}
+		// 写有代理密码的文件只有属主可读写
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+			t.Errorf("%s mode = %v, want 0600", name, info.Mode().Perm())
+		}
+	}
+
+	if err := m.UnsetProxy(); err != nil {
+		t.Fatalf("UnsetProxy() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(m.Root, ProxyFile)); !os.IsNotExist(err) {
+		t.Errorf("%s still exists after UnsetProxy", ProxyFile)
+	}
+	if got, _ := ioutil.ReadFile(filepath.Join(m.Root, "/etc/dnf/dnf.conf")); string(got) != "[main]\ngpgcheck=1\n" {
+		t.Errorf("dnf.conf = %q after UnsetProxy", got)
+	}
+}
+
+func TestProxyTransactionRollback(t *testing.T) {
+	m := proxyRoot(t)
+	tx, err := m.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Close()
+	tx.WriteConfigFile(ProxyFile, []byte("url: http://proxy.example.com:3128\n"), 0600)
+	if err := m.updateMainConf(tx, func(main *Section) { setSectionProxy(main, &utils.Proxy{URL: "http://proxy.example.com:3128"}) }); err != nil {
+		t.Fatal(err)
+	}
+	// 两个配置文件都已替换后命令失败，全部恢复
+	tx.Run(false, "false")
+	if err := m.apply(tx, false); err == nil || !strings.Contains(err.Error(), "changes rolled back") {
+		t.Fatalf("apply() error = %v, want rollback", err)
+	}
+	path := filepath.Join(m.Root, "/etc/dnf/dnf.conf")
+	if got, _ := ioutil.ReadFile(path); string(got) != "[main]\ngpgcheck=1\n" {
+		t.Errorf("dnf.conf = %q, want unchanged", got)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("dnf.conf mode changed after rollback")
+	}
+	if _, err := os.Stat(filepath.Join(m.Root, ProxyFile)); !os.IsNotExist(err) {
+		t.Errorf("%s not removed by rollback", ProxyFile)
+	}
+}
+
+func TestSetProxyDryRun(t *testing.T) {
+	m := proxyRoot(t)
+	m.DryRun = true
+	if err := m.SetProxy(&utils.Proxy{URL: "http://proxy.example.com:3128"}); err != nil {
+		t.Fatalf("SetProxy() error = %v", err)
+	}
+	if len(m.Plans) != 1 {
+		t.Fatalf("got %d plans, want 1", len(m.Plans))
+	}
+	var names []string
+	for _, change := range m.Plans[0].Changes {
+		names = append(names, string(change.Action)+" "+change.Name)
+	}
+	if got := strings.Join(names, ","); got != "modify /etc/dnf/dnf.conf,create /etc/yuv/proxy.yaml" {
+		t.Errorf("plan = %s", got)
+	}
+	if _, err := os.Stat(filepath.Join(m.Root, ProxyFile)); !os.IsNotExist(err) {
+		t.Errorf("DryRun wrote %s", ProxyFile)
+	}
+}
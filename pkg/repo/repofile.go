@@ -0,0 +1,462 @@
+package repo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// lineKind 行类型
+type lineKind int
+
+const (
+	kindBlank   lineKind = iota // 空行
+	kindComment                 // 注释行
+	kindEntry                   // 键值对（含续行）
+)
+
+// entry .repo 文件中的一行（或带续行的一个键值对）
+type entry struct {
+	kind   lineKind
+	raw    []string // 原始物理行（含换行符），未修改时原样输出
+	key    string   // 键名
+	sep    string   // 键与值之间的分隔符原文，如 "=" 或 " = "
+	value  string   // 值，多行值以 "\n" 连接
+	indent string   // 续行缩进
+}
+
+// Section .repo 文件中的一个 [section]
+type Section struct {
+	ID      string
+	header  string // 原始节头行（含换行符）
+	entries []*entry
+	file    *RepoFile
+}
+
+// RepoFile yum/dnf .repo 文件（INI 格式）
+// 解析时保留注释、空行、键顺序与续行，未修改时可逐字节还原
+type RepoFile struct {
+	preamble []*entry // 第一个节之前的注释和空行
+	Sections []*Section
+	newline  string // 文件使用的换行符
+}
+
+// ParseRepoFile 解析 .repo 文件内容
+func ParseRepoFile(data []byte) (*RepoFile, error) {
+	content := string(data)
+	f := &RepoFile{newline: "\n"}
+	if strings.Contains(content, "\r\n") {
+		f.newline = "\r\n"
+	}
+
+	var current *Section
+	var last *entry // 最近的键值对，用于挂接续行
+	for i, raw := range strings.SplitAfter(content, "\n") {
+		if raw == "" {
+			continue
+		}
+		lineNo := i + 1
+		text := strings.TrimRight(raw, "\r\n")
+		trimmed := strings.TrimSpace(text)
+
+		switch {
+		case trimmed == "":
+			last = nil
+			f.appendEntry(current, &entry{kind: kindBlank, raw: []string{raw}})
+		case trimmed[0] == '#' || trimmed[0] == ';':
+			last = nil
+			f.appendEntry(current, &entry{kind: kindComment, raw: []string{raw}})
+		case last != nil && (text[0] == ' ' || text[0] == '\t'):
+			// 续行：以空白开头且紧跟在键值对之后
+			last.raw = append(last.raw, raw)
+			if last.value == "" {
+				last.value = trimmed
+			} else {
+				last.value += "\n" + trimmed
+			}
+			if last.indent == "" {
+				last.indent = text[:len(text)-len(strings.TrimLeft(text, " \t"))]
+			}
+		case trimmed[0] == '[':
+			end := strings.Index(trimmed, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated section header", lineNo)
+			}
+			id := strings.TrimSpace(trimmed[1:end])
+			if id == "" {
+				return nil, fmt.Errorf("line %d: empty section id", lineNo)
+			}
+			last = nil
+			current = &Section{ID: id, header: raw, file: f}
+			f.Sections = append(f.Sections, current)
+		default:
+			idx := strings.Index(text, "=")
+			if idx < 0 {
+				return nil, fmt.Errorf("line %d: expected key=value, got %q", lineNo, trimmed)
+			}
+			if current == nil {
+				return nil, fmt.Errorf("line %d: key outside of any section", lineNo)
+			}
+			key := strings.TrimSpace(text[:idx])
+			if key == "" {
+				return nil, fmt.Errorf("line %d: empty key", lineNo)
+			}
+			rest := text[idx+1:]
+			value := strings.TrimSpace(rest)
+			sep := text[len(strings.TrimRight(text[:idx], " \t")) : idx+1+len(rest)-len(strings.TrimLeft(rest, " \t"))]
+			last = &entry{kind: kindEntry, raw: []string{raw}, key: key, sep: sep, value: value}
+			current.entries = append(current.entries, last)
+		}
+	}
+
+	return f, nil
+}
+
+// LoadRepoFile 读取并解析 .repo 文件
+func LoadRepoFile(path string) (*RepoFile, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := ParseRepoFile(content)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s failed: %v", path, err)
+	}
+	return f, nil
+}
+
+// NewRepoFile 创建空的 .repo 文件
+func NewRepoFile() *RepoFile {
+	return &RepoFile{newline: "\n"}
+}
+
+// appendEntry 将注释或空行挂到当前节，没有节时挂到文件头部
+func (f *RepoFile) appendEntry(s *Section, e *entry) {
+	if s == nil {
+		f.preamble = append(f.preamble, e)
+		return
+	}
+	s.entries = append(s.entries, e)
+}
+
+// Bytes 序列化为 .repo 文件内容
+func (f *RepoFile) Bytes() []byte {
+	var b strings.Builder
+	for _, e := range f.preamble {
+		b.WriteString(strings.Join(e.raw, ""))
+	}
+	for _, s := range f.Sections {
+		b.WriteString(s.header)
+		for _, e := range s.entries {
+			b.WriteString(strings.Join(e.raw, ""))
+		}
+	}
+	return []byte(b.String())
+}
+
+// Save 写入文件，保留原有文件权限
+func (f *RepoFile) Save(path string) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	return ioutil.WriteFile(path, f.Bytes(), mode)
+}
+
+// AddComment 在文件头部（第一个节之前）追加一行注释，text 不含开头的 #
+func (f *RepoFile) AddComment(text string) {
+	f.preamble = append(f.preamble, &entry{kind: kindComment, raw: []string{"# " + text + f.newline}})
+}
+
+// Comments 返回文件头部的注释内容，去掉开头的 # 或 ; 和空白
+func (f *RepoFile) Comments() []string {
+	var comments []string
+	for _, e := range f.preamble {
+		if e.kind == kindComment {
+			comments = append(comments, strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(e.raw[0]), "#;")))
+		}
+	}
+	return comments
+}
+
+// Section 根据 ID 获取节
+func (f *RepoFile) Section(id string) *Section {
+	for _, s := range f.Sections {
+		if s.ID == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// AddSection 在文件末尾追加一个新节，已存在时直接返回
+func (f *RepoFile) AddSection(id string) *Section {
+	if s := f.Section(id); s != nil {
+		return s
+	}
+
+	// 保证前一段以换行结尾，并用空行分隔
+	if n := len(f.Sections); n > 0 {
+		prev := f.Sections[n-1]
+		prev.terminate()
+		if len(prev.entries) == 0 || prev.entries[len(prev.entries)-1].kind != kindBlank {
+			prev.entries = append(prev.entries, &entry{kind: kindBlank, raw: []string{f.newline}})
+		}
+	} else if n := len(f.preamble); n > 0 {
+		last := f.preamble[n-1]
+		if !strings.HasSuffix(last.raw[len(last.raw)-1], "\n") {
+			last.raw[len(last.raw)-1] += f.newline
+		}
+	}
+
+	s := &Section{ID: id, header: "[" + id + "]" + f.newline, file: f}
+	f.Sections = append(f.Sections, s)
+	return s
+}
+
+// RemoveSection 删除指定节，返回是否存在
+func (f *RepoFile) RemoveSection(id string) bool {
+	for i, s := range f.Sections {
+		if s.ID == id {
+			f.Sections = append(f.Sections[:i], f.Sections[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// terminate 保证节的最后一行以换行结尾
+func (s *Section) terminate() {
+	if len(s.entries) == 0 {
+		if !strings.HasSuffix(s.header, "\n") {
+			s.header += s.file.newline
+		}
+		return
+	}
+	last := s.entries[len(s.entries)-1]
+	if !strings.HasSuffix(last.raw[len(last.raw)-1], "\n") {
+		last.raw[len(last.raw)-1] += s.file.newline
+	}
+}
+
+// find 查找键值对，键名不区分大小写
+func (s *Section) find(key string) *entry {
+	for _, e := range s.entries {
+		if e.kind == kindEntry && strings.EqualFold(e.key, key) {
+			return e
+		}
+	}
+	return nil
+}
+
+// Keys 按文件中的顺序返回所有键
+func (s *Section) Keys() []string {
+	var keys []string
+	for _, e := range s.entries {
+		if e.kind == kindEntry {
+			keys = append(keys, e.key)
+		}
+	}
+	return keys
+}
+
+// Get 获取键值，多行值以 "\n" 连接
+func (s *Section) Get(key string) (string, bool) {
+	if e := s.find(key); e != nil {
+		return e.value, true
+	}
+	return "", false
+}
+
+// Value 获取键值，不存在时返回空字符串
+func (s *Section) Value(key string) string {
+	value, _ := s.Get(key)
+	return value
+}
+
+// Values 获取多值键（如 baseurl）的所有值，按换行、逗号和空白拆分
+func (s *Section) Values(key string) []string {
+	return strings.FieldsFunc(s.Value(key), func(r rune) bool {
+		return r == '\n' || r == ',' || r == ' ' || r == '\t'
+	})
+}
+
+// Set 设置键值，已存在时原位修改并保留分隔符风格，否则追加到节末尾
+func (s *Section) Set(key, value string) {
+	if e := s.find(key); e != nil {
+		if e.value == value {
+			return
+		}
+		e.value = value
+		e.render(s.file.newline)
+		return
+	}
+
+	e := &entry{kind: kindEntry, key: key, sep: "=", value: value}
+	e.render(s.file.newline)
+
+	// 插入到最后一个键值对之后，保留节尾部的空行和注释
+	pos := 0
+	for i, existing := range s.entries {
+		if existing.kind == kindEntry {
+			pos = i + 1
+		}
+	}
+	if pos == 0 {
+		s.terminate()
+	} else {
+		prev := s.entries[pos-1]
+		if !strings.HasSuffix(prev.raw[len(prev.raw)-1], "\n") {
+			prev.raw[len(prev.raw)-1] += s.file.newline
+		}
+	}
+	s.entries = append(s.entries[:pos], append([]*entry{e}, s.entries[pos:]...)...)
+}
+
+// Delete 删除键，返回是否存在
+func (s *Section) Delete(key string) bool {
+	for i, e := range s.entries {
+		if e.kind == kindEntry && strings.EqualFold(e.key, key) {
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Comment 将键注释掉（如 mirrorlist），保留原内容便于手工恢复，返回是否存在
+func (s *Section) Comment(key string) bool {
+	found := false
+	for _, e := range s.entries {
+		if e.kind == kindEntry && strings.EqualFold(e.key, key) {
+			for i, line := range e.raw {
+				e.raw[i] = "#" + line
+			}
+			e.kind = kindComment
+			found = true
+		}
+	}
+	return found
+}
+
+// Commented 获取被注释掉的键值（如 #baseurl=...），不存在时返回 false
+func (s *Section) Commented(key string) (string, bool) {
+	for _, e := range s.entries {
+		if e.kind != kindComment {
+			continue
+		}
+		if k, value, ok := parseCommented(e.raw[0]); ok && strings.EqualFold(k, key) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// Uncomment 恢复第一处被注释掉的键，之后可用 Set 原位修改，返回是否存在
+func (s *Section) Uncomment(key string) bool {
+	for _, e := range s.entries {
+		if e.kind != kindComment {
+			continue
+		}
+		if k, value, ok := parseCommented(e.raw[0]); ok && strings.EqualFold(k, key) {
+			e.kind, e.key, e.sep, e.value = kindEntry, k, "=", value
+			e.render(s.file.newline)
+			return true
+		}
+	}
+	return false
+}
+
+// parseCommented 解析形如 "#key=value" 的注释行
+func parseCommented(raw string) (key, value string, ok bool) {
+	text := strings.TrimLeft(strings.TrimSpace(raw), "#; \t")
+	idx := strings.Index(text, "=")
+	if idx <= 0 {
+		return "", "", false
+	}
+	key = strings.TrimSpace(text[:idx])
+	if strings.ContainsAny(key, " \t") {
+		return "", "", false
+	}
+	return key, strings.TrimSpace(text[idx+1:]), true
+}
+
+// Bool 获取布尔键值，不存在时返回默认值
+func (s *Section) Bool(key string, def bool) (bool, error) {
+	value, ok := s.Get(key)
+	if !ok {
+		return def, nil
+	}
+	return ParseBool(value)
+}
+
+// SetBool 设置布尔键值，尽量沿用原有的写法（1/0、yes/no、true/false、on/off）
+func (s *Section) SetBool(key string, b bool) {
+	old, _ := s.Get(key)
+	s.Set(key, formatBool(old, b))
+}
+
+// Enabled 源是否启用，未设置 enabled 时 dnf 默认启用
+func (s *Section) Enabled() bool {
+	enabled, err := s.Bool("enabled", true)
+	if err != nil {
+		return true
+	}
+	return enabled
+}
+
+// render 根据当前值重新生成原始行
+func (e *entry) render(newline string) {
+	lines := strings.Split(e.value, "\n")
+	indent := e.indent
+	if indent == "" {
+		indent = "        "
+	}
+
+	raw := []string{e.key + e.sep + lines[0] + newline}
+	for _, line := range lines[1:] {
+		raw = append(raw, indent+line+newline)
+	}
+	e.raw = raw
+}
+
+// ParseBool 解析 yum/dnf 支持的布尔写法
+func ParseBool(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "yes", "true", "on":
+		return true, nil
+	case "0", "no", "false", "off":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean value %q", value)
+}
+
+// formatBool 按照原值的写法格式化布尔值
+func formatBool(old string, b bool) string {
+	pairs := [][2]string{{"1", "0"}, {"yes", "no"}, {"true", "false"}, {"on", "off"}}
+	result := "1"
+	if !b {
+		result = "0"
+	}
+	for _, pair := range pairs {
+		lower := strings.ToLower(strings.TrimSpace(old))
+		if lower != pair[0] && lower != pair[1] {
+			continue
+		}
+		if b {
+			result = pair[0]
+		} else {
+			result = pair[1]
+		}
+		// 保持原有的大小写风格
+		switch trimmed := strings.TrimSpace(old); {
+		case trimmed == strings.ToUpper(trimmed) && len(trimmed) > 1:
+			result = strings.ToUpper(result)
+		case trimmed != strings.ToLower(trimmed):
+			result = strings.ToUpper(result[:1]) + result[1:]
+		}
+		break
+	}
+	return result
+}
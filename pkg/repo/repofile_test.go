@@ -0,0 +1,310 @@
+package repo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRepoFileRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"空文件", ""},
+		{"只有注释", "# comment\n; another\n"},
+		{
+			name: "注释、空行和键顺序",
+			content: "# header comment\n\n" +
+				"[baseos]\nname=BaseOS\n# mirrorlist=https://example.com/ml\nbaseurl = https://example.com/baseos/\n\n" +
+				"; trailing comment\n[appstream]\nname=AppStream\nenabled=1\n",
+		},
+		{"CRLF 换行", "[baseos]\r\nname=BaseOS\r\nbaseurl=https://example.com/\r\n\r\n[appstream]\r\nenabled=0\r\n"},
+		{
+			name: "续行",
+			content: "[epel]\nbaseurl=https://a.example.com/\n        https://b.example.com/\n\thttps://c.example.com/\n" +
+				"gpgkey=file:///etc/pki/rpm-gpg/A\n  file:///etc/pki/rpm-gpg/B\n",
+		},
+		{"没有结尾换行", "[baseos]\nname=BaseOS\nenabled=1"},
+		{"分隔符两侧的空白和行尾空白", "[baseos]\nname =  BaseOS  \n  \nenabled\t=\t1\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := ParseRepoFile([]byte(tt.content))
+			if err != nil {
+				t.Fatalf("ParseRepoFile() error = %v", err)
+			}
+			if got := string(file.Bytes()); got != tt.content {
+				t.Errorf("Bytes() = %q, want %q", got, tt.content)
+			}
+		})
+	}
+}
+
+func TestParseRepoFileValues(t *testing.T) {
+	content := "[epel]\nname = EPEL\nbaseurl=https://a.example.com/\n        https://b.example.com/\n#enabled=0\nEnabled=1\n"
+	file, err := ParseRepoFile([]byte(content))
+	if err != nil {
+		t.Fatalf("ParseRepoFile() error = %v", err)
+	}
+	section := file.Section("epel")
+	if section == nil {
+		t.Fatal("Section(epel) = nil")
+	}
+	if got := section.Value("name"); got != "EPEL" {
+		t.Errorf("name = %q, want EPEL", got)
+	}
+	if got := section.Value("baseurl"); got != "https://a.example.com/\nhttps://b.example.com/" {
+		t.Errorf("baseurl = %q", got)
+	}
+	if got := strings.Join(section.Values("baseurl"), ","); got != "https://a.example.com/,https://b.example.com/" {
+		t.Errorf("Values(baseurl) = %s", got)
+	}
+	// 键名不区分大小写，注释掉的键不参与查找
+	if got := section.Value("enabled"); got != "1" {
+		t.Errorf("enabled = %q, want 1", got)
+	}
+	if got := strings.Join(section.Keys(), ","); got != "name,baseurl,Enabled" {
+		t.Errorf("Keys() = %s", got)
+	}
+}
+
+func TestParseRepoFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"节头未结束", "[baseos\nname=BaseOS\n", "line 1: unterminated section header"},
+		{"空节 ID", "[ ]\n", "line 1: empty section id"},
+		{"节外的键", "name=BaseOS\n", "line 1: key outside of any section"},
+		{"缺少等号", "[baseos]\nname\n", "line 2: expected key=value"},
+		{"空键名", "[baseos]\n=BaseOS\n", "line 2: empty key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseRepoFile([]byte(tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseRepoFile() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSectionSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		key     string
+		value   string
+		want    string
+	}{
+		{
+			name:    "修改已有的键并保留分隔符",
+			content: "[baseos]\nname = BaseOS\nbaseurl = https://old.example.com/\nenabled=1\n",
+			key:     "baseurl",
+			value:   "https://new.example.com/",
+			want:    "[baseos]\nname = BaseOS\nbaseurl = https://new.example.com/\nenabled=1\n",
+		},
+		{
+			name:    "值不变时不改动原文",
+			content: "[baseos]\nBaseURL  =  https://example.com/  \n",
+			key:     "baseurl",
+			value:   "https://example.com/",
+			want:    "[baseos]\nBaseURL  =  https://example.com/  \n",
+		},
+		{
+			name:    "多行值沿用原有缩进",
+			content: "[epel]\nbaseurl=https://a.example.com/\n\thttps://b.example.com/\nenabled=1\n",
+			key:     "baseurl",
+			value:   "https://c.example.com/\nhttps://d.example.com/",
+			want:    "[epel]\nbaseurl=https://c.example.com/\n\thttps://d.example.com/\nenabled=1\n",
+		},
+		{
+			name:    "新键追加到最后一个键之后",
+			content: "[baseos]\nname=BaseOS\n\n# comment\n[appstream]\nname=AppStream\n",
+			key:     "priority",
+			value:   "1",
+			want:    "[baseos]\nname=BaseOS\npriority=1\n\n# comment\n[appstream]\nname=AppStream\n",
+		},
+		{
+			name:    "没有结尾换行时追加新键",
+			content: "[baseos]\nname=BaseOS",
+			key:     "enabled",
+			value:   "1",
+			want:    "[baseos]\nname=BaseOS\nenabled=1\n",
+		},
+		{
+			name:    "没有结尾换行时修改最后一个键",
+			content: "[baseos]\nname=BaseOS\nenabled=1",
+			key:     "enabled",
+			value:   "0",
+			want:    "[baseos]\nname=BaseOS\nenabled=0\n",
+		},
+		{
+			name:    "空节追加新键",
+			content: "[baseos]",
+			key:     "name",
+			value:   "BaseOS",
+			want:    "[baseos]\nname=BaseOS\n",
+		},
+		{
+			name:    "CRLF 文件使用 CRLF",
+			content: "[baseos]\r\nname=BaseOS\r\n",
+			key:     "enabled",
+			value:   "1",
+			want:    "[baseos]\r\nname=BaseOS\r\nenabled=1\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := ParseRepoFile([]byte(tt.content))
+			if err != nil {
+				t.Fatalf("ParseRepoFile() error = %v", err)
+			}
+			file.Sections[0].Set(tt.key, tt.value)
+			if got := string(file.Bytes()); got != tt.want {
+				t.Errorf("Bytes() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSectionSetBool(t *testing.T) {
+	tests := []struct {
+		old  string
+		b    bool
+		want string
+	}{
+		{"1", false, "0"},
+		{"0", true, "1"},
+		{"True", false, "False"},
+		{"true", false, "false"},
+		{"TRUE", false, "FALSE"},
+		{"yes", false, "no"},
+		{"No", true, "Yes"},
+		{"off", true, "on"},
+		{"", true, "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.old+"-"+tt.want, func(t *testing.T) {
+			content := "[baseos]\nenabled=" + tt.old + "\n"
+			if tt.old == "" {
+				content = "[baseos]\n"
+			}
+			file, err := ParseRepoFile([]byte(content))
+			if err != nil {
+				t.Fatalf("ParseRepoFile() error = %v", err)
+			}
+			section := file.Sections[0]
+			section.SetBool("enabled", tt.b)
+			if got := section.Value("enabled"); got != tt.want {
+				t.Errorf("SetBool(%v) on %q = %q, want %q", tt.b, tt.old, got, tt.want)
+			}
+			if got, err := section.Bool("enabled", !tt.b); err != nil || got != tt.b {
+				t.Errorf("Bool() = %v, %v, want %v", got, err, tt.b)
+			}
+		})
+	}
+}
+
+func TestSectionCommentUncomment(t *testing.T) {
+	content := "[baseos]\nname=BaseOS\nmirrorlist=https://mirrors.example.com/ml\n#baseurl=https://example.com/baseos/\nenabled=1\n"
+	file, err := ParseRepoFile([]byte(content))
+	if err != nil {
+		t.Fatalf("ParseRepoFile() error = %v", err)
+	}
+	section := file.Sections[0]
+
+	if !section.Comment("mirrorlist") {
+		t.Fatal("Comment(mirrorlist) = false")
+	}
+	if section.Comment("missing") {
+		t.Error("Comment(missing) = true")
+	}
+	if _, ok := section.Get("mirrorlist"); ok {
+		t.Error("mirrorlist still set after Comment")
+	}
+	if value, ok := section.Commented("mirrorlist"); !ok || value != "https://mirrors.example.com/ml" {
+		t.Errorf("Commented(mirrorlist) = %q, %v", value, ok)
+	}
+
+	if !section.Uncomment("baseurl") {
+		t.Fatal("Uncomment(baseurl) = false")
+	}
+	if section.Uncomment("missing") {
+		t.Error("Uncomment(missing) = true")
+	}
+	section.Set("baseurl", "https://mirror.example.com/baseos/")
+
+	want := "[baseos]\nname=BaseOS\n#mirrorlist=https://mirrors.example.com/ml\nbaseurl=https://mirror.example.com/baseos/\nenabled=1\n"
+	if got := string(file.Bytes()); got != want {
+		t.Errorf("Bytes() = %q, want %q", got, want)
+	}
+}
+
+func TestRemoveSection(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		id      string
+		found   bool
+		want    string
+	}{
+		{
+			name:    "删除中间的节及其后的注释和空行",
+			content: "# header\n[a]\nname=A\n\n[b]\nname=B\n\n# about c\n[c]\nname=C\n",
+			id:      "b",
+			found:   true,
+			want:    "# header\n[a]\nname=A\n\n[c]\nname=C\n",
+		},
+		{
+			name:    "删除最后一个节",
+			content: "[a]\nname=A\n\n[b]\nname=B\n# trailing\n",
+			id:      "b",
+			found:   true,
+			want:    "[a]\nname=A\n\n",
+		},
+		{
+			name:    "删除第一个节保留文件头部注释",
+			content: "# header\n\n[a]\nname=A\n\n[b]\nname=B\n",
+			id:      "a",
+			found:   true,
+			want:    "# header\n\n[b]\nname=B\n",
+		},
+		{
+			name:    "节不存在",
+			content: "[a]\nname=A\n",
+			id:      "b",
+			want:    "[a]\nname=A\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := ParseRepoFile([]byte(tt.content))
+			if err != nil {
+				t.Fatalf("ParseRepoFile() error = %v", err)
+			}
+			if found := file.RemoveSection(tt.id); found != tt.found {
+				t.Errorf("RemoveSection(%s) = %v, want %v", tt.id, found, tt.found)
+			}
+			if got := string(file.Bytes()); got != tt.want {
+				t.Errorf("Bytes() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddSection(t *testing.T) {
+	file, err := ParseRepoFile([]byte("# header\n[a]\nname=A"))
+	if err != nil {
+		t.Fatalf("ParseRepoFile() error = %v", err)
+	}
+	if file.AddSection("a") != file.Sections[0] {
+		t.Error("AddSection(a) did not return the existing section")
+	}
+	file.AddSection("b").Set("name", "B")
+	want := "# header\n[a]\nname=A\n\n[b]\nname=B\n"
+	if got := string(file.Bytes()); got != want {
+		t.Errorf("Bytes() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,59 @@
+package repo
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// RepoMD repodata/repomd.xml 的内容
+type RepoMD struct {
+	Revision string       `xml:"revision"`
+	Data     []RepoMDData `xml:"data"`
+}
+
+// RepoMDData repomd.xml 中的一条元数据记录（primary、filelists 等）
+type RepoMDData struct {
+	Type     string `xml:"type,attr"`
+	Checksum struct {
+		Type  string `xml:"type,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"checksum"`
+	Location struct {
+		Href string `xml:"href,attr"`
+	} `xml:"location"`
+	Size      int64 `xml:"size"`
+	Timestamp int64 `xml:"timestamp"`
+}
+
+// ParseRepoMD 解析 repomd.xml
+func ParseRepoMD(data []byte) (*RepoMD, error) {
+	var md RepoMD
+	if err := xml.Unmarshal(data, &md); err != nil {
+		return nil, fmt.Errorf("parse repomd.xml failed: %v", err)
+	}
+	if len(md.Data) == 0 {
+		return nil, fmt.Errorf("repomd.xml contains no metadata")
+	}
+	return &md, nil
+}
+
+// Find 根据类型查找元数据记录
+func (md *RepoMD) Find(dataType string) *RepoMDData {
+	for i := range md.Data {
+		if md.Data[i].Type == dataType {
+			return &md.Data[i]
+		}
+	}
+	return nil
+}
+
+// repomdURL 拼接源地址下的 repodata/repomd.xml 地址
+func repomdURL(baseURL string) string {
+	return joinURL(baseURL, "repodata/repomd.xml")
+}
+
+// joinURL 拼接源地址与相对路径
+func joinURL(baseURL, path string) string {
+	return strings.TrimRight(baseURL, "/") + "/" + strings.TrimLeft(path, "/")
+}
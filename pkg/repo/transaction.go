@@ -0,0 +1,518 @@
+package repo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"yuv/internal/utils"
+)
+
+// Transaction 源配置目录的一次事务：新文件先写入与源配置目录同一文件系统的暂存目录，
+// 校验通过后再逐个原子替换；提交过程中任何一步失败，都会把源配置目录和配置文件恢复到提交前的状态
+type Transaction struct {
+	m        *Manager
+	stageDir string                 // 暂存目录，repos 子目录存放 .repo 文件，files 子目录存放其他文件
+	writes   map[string]os.FileMode // 待写入的文件 -> 权限
+	deletes  map[string]bool        // 待删除的文件
+	configs  map[string]*fileState  // 源配置目录之外的配置文件（相对于备用根目录）-> 新的内容和权限，nil 表示删除
+	commands []*Command             // 文件替换完成后执行的命令
+}
+
+// Command 事务提交时执行的外部命令
+type Command struct {
+	Args        []string `json:"args"`
+	IgnoreError bool     `json:"ignore_error,omitempty"` // 失败时继续执行，如禁用不存在的模块
+	Undo        []string `json:"undo,omitempty"`         // 事务回滚时撤销该命令的命令，如卸载刚安装的 RPM
+}
+
+// String 返回命令行
+func (c *Command) String() string {
+	return strings.Join(c.Args, " ")
+}
+
+// Plan 事务将要进行的变更，用于 --dry-run 预览
+type Plan struct {
+	Changes  []*FileChange `json:"changes"`
+	Commands []*Command    `json:"commands,omitempty"`
+}
+
+// fileState 文件内容和权限，用于回滚
+type fileState struct {
+	content []byte
+	mode    os.FileMode
+}
+
+// Begin 开始一个事务，使用完毕后需调用 Close 清理暂存目录
+func (m *Manager) Begin() (*Transaction, error) {
+	// 暂存目录与源配置目录位于同一文件系统，保证 rename 是原子操作；
+	// 预览时不提交，使用系统临时目录，不需要源配置目录所在位置的写权限
+	parent := filepath.Dir(m.RepoDir)
+	if m.DryRun {
+		parent = os.TempDir()
+	}
+	stageDir, err := ioutil.TempDir(parent, ".yuv-stage-")
+	if err != nil {
+		return nil, fmt.Errorf("create stage directory failed: %v", err)
+	}
+	for _, dir := range []string{"repos", "files"} {
+		if err := os.Mkdir(filepath.Join(stageDir, dir), 0755); err != nil {
+			os.RemoveAll(stageDir)
+			return nil, fmt.Errorf("create stage directory failed: %v", err)
+		}
+	}
+
+	return &Transaction{
+		m:        m,
+		stageDir: stageDir,
+		writes:   map[string]os.FileMode{},
+		deletes:  map[string]bool{},
+		configs:  map[string]*fileState{},
+	}, nil
+}
+
+// WriteFile 暂存要写入源配置目录的文件，文件中写有代理密码时只有属主可读写
+func (t *Transaction) WriteFile(name string, content []byte, mode os.FileMode) error {
+	return t.writeFile(name, content, credentialMode(content, mode))
+}
+
+// RestoreFile 暂存从快照恢复的文件，权限与快照清单中记录的完全一致
+func (t *Transaction) RestoreFile(name string, content []byte, mode os.FileMode) error {
+	return t.writeFile(name, content, mode)
+}
+
+// writeFile 以指定权限暂存要写入源配置目录的文件
+func (t *Transaction) writeFile(name string, content []byte, mode os.FileMode) error {
+	if name == "" || name == "." || name == ".." || name != filepath.Base(name) {
+		return fmt.Errorf("invalid repo file name %q", name)
+	}
+	path := filepath.Join(t.stageDir, "repos", name)
+	if err := ioutil.WriteFile(path, content, mode); err != nil {
+		return fmt.Errorf("stage %s failed: %v", name, err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		return fmt.Errorf("stage %s failed: %v", name, err)
+	}
+	t.writes[name] = mode
+	delete(t.deletes, name)
+	return nil
+}
+
+// Remove 标记要从源配置目录删除的文件
+func (t *Transaction) Remove(name string) {
+	if _, ok := t.writes[name]; ok {
+		os.Remove(filepath.Join(t.stageDir, "repos", name))
+		delete(t.writes, name)
+	}
+	t.deletes[name] = true
+}
+
+// RemoveRepoFiles 标记删除源配置目录中现有的所有 .repo 文件
+func (t *Transaction) RemoveRepoFiles() error {
+	files, err := ioutil.ReadDir(t.m.RepoDir)
+	if err != nil {
+		return fmt.Errorf("read repo directory failed: %v", err)
+	}
+	for _, file := range files {
+		if file.Mode().IsRegular() && strings.HasSuffix(file.Name(), ".repo") {
+			t.Remove(file.Name())
+		}
+	}
+	return nil
+}
+
+// WriteConfigFile 暂存要写入源配置目录之外的配置文件（相对于备用根目录，如 /etc/dnf/dnf.conf），
+// 文件中写有代理密码时只有属主可读写
+func (t *Transaction) WriteConfigFile(name string, content []byte, mode os.FileMode) {
+	t.configs[name] = &fileState{content: content, mode: credentialMode(content, mode)}
+}
+
+// RemoveConfigFile 标记要删除的源配置目录之外的配置文件（相对于备用根目录）
+func (t *Transaction) RemoveConfigFile(name string) {
+	t.configs[name] = nil
+}
+
+// StageFile 在暂存目录中保存提交时命令需要的文件（如下载的 RPM 包），返回其路径
+func (t *Transaction) StageFile(name string, content []byte) (string, error) {
+	path := filepath.Join(t.stageDir, "files", filepath.Base(name))
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		return "", fmt.Errorf("stage %s failed: %v", name, err)
+	}
+	return path, nil
+}
+
+// Run 添加提交时执行的命令，命令在文件替换完成后按添加顺序执行；
+// 返回的命令可设置 Undo，提交失败时撤销已成功执行的命令
+func (t *Transaction) Run(ignoreError bool, args ...string) *Command {
+	command := &Command{Args: args, IgnoreError: ignoreError}
+	t.commands = append(t.commands, command)
+	return command
+}
+
+// Validate 解析暂存的 .repo 文件，并确认其中每个已启用的源都能下载到元数据
+func (t *Transaction) Validate() error {
+	var expand func(string) string
+	for _, name := range t.sortedWrites() {
+		if !strings.HasSuffix(name, ".repo") {
+			continue
+		}
+		file, err := LoadRepoFile(filepath.Join(t.stageDir, "repos", name))
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		for _, section := range file.Sections {
+			if !section.Enabled() {
+				continue
+			}
+			if expand == nil {
+				if expand, err = t.m.expander(); err != nil {
+					return err
+				}
+			}
+			if err := ValidateSection(section, expand); err != nil {
+				return fmt.Errorf("repo %s: %v", section.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Commit 提交事务：删除文件、原子替换暂存的文件和配置文件，再执行命令；
+// 任何一步失败都会按相反顺序执行已成功命令的 Undo（如卸载刚安装的 RPM），再恢复源配置目录和配置文件
+func (t *Transaction) Commit() (err error) {
+	previous, err := readDirState(t.m.RepoDir)
+	if err != nil {
+		return err
+	}
+	previousConfigs, err := t.readConfigState()
+	if err != nil {
+		return err
+	}
+	var done []*Command
+	defer func() {
+		if err == nil {
+			return
+		}
+		rollbackErr := undoCommands(done)
+		if restoreErr := restoreDirState(t.m.RepoDir, previous); restoreErr != nil {
+			rollbackErr = restoreErr
+		}
+		if restoreErr := t.writeConfigState(previousConfigs); restoreErr != nil {
+			rollbackErr = restoreErr
+		}
+		if rollbackErr != nil {
+			err = fmt.Errorf("%v; rollback failed: %v", err, rollbackErr)
+		} else {
+			err = fmt.Errorf("%v (changes rolled back)", err)
+		}
+	}()
+
+	for _, name := range t.sortedDeletes() {
+		if err := os.Remove(filepath.Join(t.m.RepoDir, name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove repo file failed: %v", err)
+		}
+	}
+	for _, name := range t.sortedWrites() {
+		if err := os.Rename(filepath.Join(t.stageDir, "repos", name), filepath.Join(t.m.RepoDir, name)); err != nil {
+			return fmt.Errorf("write repo file failed: %v", err)
+		}
+	}
+	if err := t.writeConfigState(t.configs); err != nil {
+		return err
+	}
+	for _, command := range t.commands {
+		output, err := exec.Command(command.Args[0], command.Args[1:]...).CombinedOutput()
+		if err != nil && !command.IgnoreError {
+			if output := strings.TrimSpace(string(output)); output != "" {
+				return fmt.Errorf("%s failed: %v: %s", command, err, output)
+			}
+			return fmt.Errorf("%s failed: %v", command, err)
+		}
+		if err == nil {
+			done = append(done, command)
+		}
+	}
+
+	return nil
+}
+
+// undoCommands 按相反顺序执行命令的 Undo，全部执行完后返回第一个错误
+func undoCommands(commands []*Command) error {
+	var firstErr error
+	for i := len(commands) - 1; i >= 0; i-- {
+		undo := commands[i].Undo
+		if len(undo) == 0 {
+			continue
+		}
+		if output, err := exec.Command(undo[0], undo[1:]...).CombinedOutput(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s failed: %v: %s", strings.Join(undo, " "), err, strings.TrimSpace(string(output)))
+		}
+	}
+	return firstErr
+}
+
+// Close 清理暂存目录，可以重复调用
+func (t *Transaction) Close() error {
+	return os.RemoveAll(t.stageDir)
+}
+
+// sortedWrites 按文件名排序返回待写入的文件
+func (t *Transaction) sortedWrites() []string {
+	var names []string
+	for name := range t.writes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sortedConfigs 按路径排序返回源配置目录之外待写入或删除的配置文件
+func (t *Transaction) sortedConfigs() []string {
+	var names []string
+	for name := range t.configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sortedDeletes 按文件名排序返回待删除的文件
+func (t *Transaction) sortedDeletes() []string {
+	var names []string
+	for name := range t.deletes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Plan 计算事务相对于当前源配置目录的变更，每个文件附带统一 diff
+func (t *Transaction) Plan() (*Plan, error) {
+	current, err := readDirState(t.m.RepoDir)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{Commands: t.commands}
+	for _, name := range t.sortedDeletes() {
+		old, ok := current[name]
+		if !ok {
+			continue
+		}
+		plan.Changes = append(plan.Changes, &FileChange{
+			Action: ChangeDelete,
+			Name:   name,
+			Diff:   utils.UnifiedDiff("a/"+name, "/dev/null", old.content, nil),
+		})
+	}
+	for _, name := range t.sortedWrites() {
+		content, err := ioutil.ReadFile(filepath.Join(t.stageDir, "repos", name))
+		if err != nil {
+			return nil, fmt.Errorf("read staged file failed: %v", err)
+		}
+		mode := t.writes[name]
+		old, ok := current[name]
+		switch {
+		case !ok:
+			plan.Changes = append(plan.Changes, &FileChange{
+				Action: ChangeCreate,
+				Name:   name,
+				Mode:   mode,
+				Diff:   utils.UnifiedDiff("/dev/null", "b/"+name, nil, content),
+			})
+		case string(old.content) != string(content):
+			plan.Changes = append(plan.Changes, &FileChange{
+				Action:  ChangeModify,
+				Name:    name,
+				Mode:    mode,
+				OldMode: old.mode,
+				Diff:    utils.UnifiedDiff("a/"+name, "b/"+name, old.content, content),
+			})
+		case old.mode != mode:
+			plan.Changes = append(plan.Changes, &FileChange{Action: ChangeMode, Name: name, Mode: mode, OldMode: old.mode})
+		}
+	}
+
+	configs, err := t.readConfigState()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range t.sortedConfigs() {
+		file, old := t.configs[name], configs[name]
+		switch {
+		case file == nil && old == nil:
+		case file == nil:
+			plan.Changes = append(plan.Changes, &FileChange{
+				Action: ChangeDelete,
+				Name:   name,
+				Diff:   utils.UnifiedDiff("a"+name, "/dev/null", old.content, nil),
+			})
+		case old == nil:
+			plan.Changes = append(plan.Changes, &FileChange{
+				Action: ChangeCreate,
+				Name:   name,
+				Mode:   file.mode,
+				Diff:   utils.UnifiedDiff("/dev/null", "b"+name, nil, file.content),
+			})
+		case string(old.content) != string(file.content):
+			plan.Changes = append(plan.Changes, &FileChange{
+				Action:  ChangeModify,
+				Name:    name,
+				Mode:    file.mode,
+				OldMode: old.mode,
+				Diff:    utils.UnifiedDiff("a"+name, "b"+name, old.content, file.content),
+			})
+		case old.mode != file.mode:
+			plan.Changes = append(plan.Changes, &FileChange{Action: ChangeMode, Name: name, Mode: file.mode, OldMode: old.mode})
+		}
+	}
+	sortChanges(plan.Changes)
+
+	return plan, nil
+}
+
+// commit 校验暂存的新源，为当前源配置创建快照后提交事务
+func (m *Manager) commit(tx *Transaction) error {
+	if !m.SkipVerify && !m.DryRun {
+		if err := tx.Validate(); err != nil {
+			return fmt.Errorf("validate new repos failed: %v", err)
+		}
+	}
+	return m.apply(tx, true)
+}
+
+// apply 提交事务，backup 为 true 时先为当前源配置创建快照；DryRun 时只记录变更预览
+func (m *Manager) apply(tx *Transaction, backup bool) error {
+	if m.DryRun {
+		plan, err := tx.Plan()
+		if err != nil {
+			return err
+		}
+		m.Plans = append(m.Plans, plan)
+		return nil
+	}
+	if backup {
+		if _, err := m.Backup(); err != nil {
+			return fmt.Errorf("backup failed: %v", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// readDirState 读取目录中所有普通文件的内容和权限
+func readDirState(dir string) (map[string]*fileState, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read repo directory failed: %v", err)
+	}
+	state := map[string]*fileState{}
+	for _, file := range files {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read repo file failed: %v", err)
+		}
+		state[file.Name()] = &fileState{content: content, mode: file.Mode().Perm()}
+	}
+	return state, nil
+}
+
+// restoreDirState 将目录恢复为 readDirState 记录的状态，只改动有差异的文件
+func restoreDirState(dir string, state map[string]*fileState) error {
+	current, err := readDirState(dir)
+	if err != nil {
+		return err
+	}
+	for name := range current {
+		if _, ok := state[name]; !ok {
+			if err := os.Remove(filepath.Join(dir, name)); err != nil {
+				return err
+			}
+		}
+	}
+	for name, file := range state {
+		path := filepath.Join(dir, name)
+		if now, ok := current[name]; !ok || string(now.content) != string(file.content) {
+			if err := ioutil.WriteFile(path, file.content, file.mode); err != nil {
+				return err
+			}
+		}
+		if err := os.Chmod(path, file.mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readConfigState 读取事务涉及的配置文件当前的内容和权限，不存在的文件记为 nil
+func (t *Transaction) readConfigState() (map[string]*fileState, error) {
+	state := map[string]*fileState{}
+	for name := range t.configs {
+		path := filepath.Join(t.m.Root, name)
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			state[name] = nil
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("read %s failed: %v", name, err)
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read %s failed: %v", name, err)
+		}
+		state[name] = &fileState{content: content, mode: info.Mode().Perm()}
+	}
+	return state, nil
+}
+
+// writeConfigState 按路径顺序原子地写入或删除配置文件（相对于备用根目录），nil 表示删除
+func (t *Transaction) writeConfigState(state map[string]*fileState) error {
+	var names []string
+	for name := range state {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := filepath.Join(t.m.Root, name)
+		file := state[name]
+		if file == nil {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("remove %s failed: %v", name, err)
+			}
+			continue
+		}
+		if err := replaceFile(path, file.content, file.mode); err != nil {
+			return fmt.Errorf("write %s failed: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// replaceFile 先写入同一目录下的临时文件，再原子替换目标文件
+func replaceFile(path string, content []byte, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".yuv-"+filepath.Base(path)+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,161 @@
+package repo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// VarDirs dnf 读取自定义变量的目录，后面的目录覆盖前面的同名变量
+var VarDirs = []string{"/etc/dnf/vars", "/etc/yum/vars"}
+
+// varPattern 匹配 $name、${name}、${name:-默认值} 和 ${name:+替代值}
+var varPattern = regexp.MustCompile(`\$(?:\{(\w+)(?::([-+])([^}]*))?\}|(\w+))`)
+
+// varNamePattern 合法的变量名（自定义变量文件名）
+var varNamePattern = regexp.MustCompile(`^\w+$`)
+
+// baseArchs 系统架构与 $basearch 的对应关系，未列出的架构与 $basearch 相同
+var baseArchs = map[string]string{
+	"i586":    "i386",
+	"i686":    "i386",
+	"armv7l":  "armhfp",
+	"armv7hl": "armhfp",
+}
+
+// Vars yum/dnf 变量表，键为不带 $ 的变量名
+type Vars map[string]string
+
+// NewVars 根据发行版、版本号和架构生成内置变量：$distro、$releasever、$releasever_major、
+// $releasever_minor、$basearch、$arch、$infra，以及 CentOS、Rocky 的 $contentdir 和 CentOS Stream 的 $stream
+func NewVars(distro, releasever, basearch string) Vars {
+	v := Vars{
+		"distro":   distro,
+		"basearch": basearch,
+		"arch":     basearch,
+		"infra":    "stock",
+	}
+	v.SetReleasever(releasever)
+
+	major, minor, _ := strings.Cut(releasever, ".")
+	switch distro {
+	case "centos":
+		v["contentdir"] = "centos"
+		if major == "7" && basearch != "x86_64" {
+			v["contentdir"] = "altarch"
+		}
+		if major != "7" && minor == "" {
+			v["stream"] = major + "-stream"
+		}
+	case "rockylinux":
+		v["contentdir"] = "pub/rocky"
+	}
+	return v
+}
+
+// SetReleasever 设置 $releasever，并同步 $releasever_major 和 $releasever_minor（第一个点之后的部分）
+func (v Vars) SetReleasever(releasever string) {
+	major, minor, _ := strings.Cut(releasever, ".")
+	v["releasever"] = releasever
+	v["releasever_major"] = major
+	v["releasever_minor"] = minor
+}
+
+// Expand 替换字符串中的变量，变量名取最长匹配，未定义的变量保持原样
+func (v Vars) Expand(s string) string {
+	return varPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		match := varPattern.FindStringSubmatch(ref)
+		if name := match[4]; name != "" {
+			if value, ok := v[name]; ok {
+				return value
+			}
+			return ref
+		}
+
+		value, ok := v[match[1]]
+		switch match[2] {
+		case "-":
+			if !ok || value == "" {
+				return v.Expand(match[3])
+			}
+		case "+":
+			if ok && value != "" {
+				return v.Expand(match[3])
+			}
+			return ""
+		default:
+			if !ok {
+				return ref
+			}
+		}
+		return value
+	})
+}
+
+// Load 读取变量目录中的自定义变量：文件名为变量名，文件第一行为值，不存在的目录忽略。
+// 自定义了 $releasever 而没有自定义 $releasever_major / $releasever_minor 时同步更新
+func (v Vars) Load(dirs ...string) error {
+	loaded := map[string]bool{}
+	for _, dir := range dirs {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("read vars directory failed: %v", err)
+		}
+		for _, file := range files {
+			if !file.Mode().IsRegular() || !varNamePattern.MatchString(file.Name()) {
+				continue
+			}
+			content, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+			if err != nil {
+				return fmt.Errorf("read var %s failed: %v", file.Name(), err)
+			}
+			value, _, _ := strings.Cut(string(content), "\n")
+			v[file.Name()] = strings.TrimSpace(value)
+			loaded[file.Name()] = true
+		}
+	}
+
+	if loaded["releasever"] {
+		major, minor, _ := strings.Cut(v["releasever"], ".")
+		if !loaded["releasever_major"] {
+			v["releasever_major"] = major
+		}
+		if !loaded["releasever_minor"] {
+			v["releasever_minor"] = minor
+		}
+	}
+	return nil
+}
+
+// Vars 返回 dnf 解析当前系统 .repo 文件时使用的变量：$releasever 为主版本号，
+// $basearch 由系统架构推导，并读取备用根目录下 VarDirs 中的自定义变量
+func (m *Manager) Vars() (Vars, error) {
+	distro, err := m.detector().Detect()
+	if err != nil {
+		return nil, fmt.Errorf("detect system failed: %v", err)
+	}
+
+	basearch := distro.Arch
+	if base, ok := baseArchs[basearch]; ok {
+		basearch = base
+	}
+	vars := NewVars(distro.Name, distro.Version, basearch)
+	vars["arch"] = distro.Arch
+	// dnf 的 $releasever 来自 system-release 软件包，为主版本号
+	vars.SetReleasever(strings.Split(distro.Version, ".")[0])
+
+	var dirs []string
+	for _, dir := range VarDirs {
+		dirs = append(dirs, filepath.Join(m.Root, dir))
+	}
+	if err := vars.Load(dirs...); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
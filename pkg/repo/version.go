@@ -0,0 +1,227 @@
+package repo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RepoVersion 源支持的一个版本，只有版本号时可以直接写成字符串；
+// url、gpgkey、release_rpm、release_package 非空时覆盖源的同名配置
+type RepoVersion struct {
+	Version        string `yaml:"version"`         // 版本号，如 20、1.30、8.4
+	URL            string `yaml:"url"`             // 该版本的源地址模板
+	GPGKey         string `yaml:"gpgkey"`          // 该版本的 GPG 密钥地址
+	ReleaseRPM     string `yaml:"release_rpm"`     // 该版本的 release RPM
+	ReleasePackage string `yaml:"release_package"` // 该版本 release RPM 的包名
+}
+
+// versionPattern 版本号允许的字符
+var versionPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// versionFields 版本条目允许的字段，解码映射时按 KnownFields 的规则检查
+var versionFields = []string{"version", "url", "gpgkey", "release_rpm", "release_package"}
+
+// UnmarshalYAML 支持字符串和映射两种写法
+func (v *RepoVersion) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&v.Version)
+	}
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			known := false
+			for _, field := range versionFields {
+				known = known || key.Value == field
+			}
+			if !known {
+				return fmt.Errorf("line %d: field %s not found in version", key.Line, key.Value)
+			}
+		}
+	}
+	type plain RepoVersion
+	return node.Decode((*plain)(v))
+}
+
+// SplitVersion 拆分 name@version 形式的源名称，未指定版本时 version 为空
+func SplitVersion(spec string) (name, version string) {
+	name, version, _ = strings.Cut(spec, "@")
+	return name, version
+}
+
+// VersionNames 返回源支持的版本号列表
+func (r *Repo) VersionNames() []string {
+	var names []string
+	for _, v := range r.Versions {
+		names = append(names, v.Version)
+	}
+	return names
+}
+
+// DefaultVersionName 返回未指定版本时使用的版本号，没有版本时返回空字符串
+func (r *Repo) DefaultVersionName() string {
+	if r.DefaultVersion != "" || len(r.Versions) == 0 {
+		return r.DefaultVersion
+	}
+	return r.Versions[len(r.Versions)-1].Version
+}
+
+// WithVersion 返回选中指定版本后的源配置副本，地址模板中的 $version、$version_nodot 替换为该版本，
+// version 为空时使用默认版本；源没有声明版本时只接受空版本
+func (r *Repo) WithVersion(version string) (*Repo, error) {
+	if len(r.Versions) == 0 {
+		if version != "" {
+			return nil, fmt.Errorf("repo %s has no versions", r.Name)
+		}
+		return r, nil
+	}
+	if version == "" {
+		version = r.DefaultVersionName()
+	}
+	var selected *RepoVersion
+	for _, v := range r.Versions {
+		if v.Version == version {
+			selected = v
+		}
+	}
+	if selected == nil {
+		return nil, fmt.Errorf("repo %s has no version %s, available: %s", r.Name, version, strings.Join(r.VersionNames(), ", "))
+	}
+
+	copied := *r
+	copied.Version = version
+	if selected.URL != "" {
+		copied.URL = selected.URL
+	}
+	if selected.GPGKey != "" {
+		copied.GPGKey = selected.GPGKey
+	}
+	if selected.ReleaseRPM != "" {
+		copied.ReleaseRPM = selected.ReleaseRPM
+	}
+	if selected.ReleasePackage != "" {
+		copied.ReleasePackage = selected.ReleasePackage
+	}
+
+	// 只替换版本变量，发行版相关的变量在生成源配置时再替换
+	vars := Vars{"version": version, "version_nodot": strings.ReplaceAll(version, ".", "")}
+	copied.URL = vars.Expand(copied.URL)
+	copied.Mirrorlist = vars.Expand(copied.Mirrorlist)
+	copied.Metalink = vars.Expand(copied.Metalink)
+	copied.VaultURL = vars.Expand(copied.VaultURL)
+	copied.GPGKey = vars.Expand(copied.GPGKey)
+	copied.ReleaseRPM = vars.Expand(copied.ReleaseRPM)
+	copied.RepoFile = vars.Expand(copied.RepoFile)
+	copied.ReleasePackage = vars.Expand(copied.ReleasePackage)
+	copied.BaseURLs = nil
+	for _, u := range r.BaseURLs {
+		copied.BaseURLs = append(copied.BaseURLs, vars.Expand(u))
+	}
+	if r.Layouts != nil {
+		copied.Layouts = map[string]*Layout{}
+		for key, layout := range r.Layouts {
+			l := *layout
+			l.URL = vars.Expand(l.URL)
+			l.Mirrorlist = vars.Expand(l.Mirrorlist)
+			l.Metalink = vars.Expand(l.Metalink)
+			l.VaultURL = vars.Expand(l.VaultURL)
+			l.GPGKey = vars.Expand(l.GPGKey)
+			l.ReleaseRPM = vars.Expand(l.ReleaseRPM)
+			l.RepoFile = vars.Expand(l.RepoFile)
+			l.BaseURLs = nil
+			for _, u := range layout.BaseURLs {
+				l.BaseURLs = append(l.BaseURLs, vars.Expand(u))
+			}
+			copied.Layouts[key] = &l
+		}
+	}
+	return &copied, nil
+}
+
+// VersionInfo 源的一个可用版本
+type VersionInfo struct {
+	Repo    string `json:"repo"`
+	Version string `json:"version"`
+	Default bool   `json:"default"` // 未指定版本时使用的版本
+	Current bool   `json:"current"` // 当前已添加的版本
+}
+
+// Versions 列出源目录中声明了版本的源及其版本，names 为空时列出所有声明了版本的源；
+// 已添加的源按 .repo 文件中的地址或已安装的 release 包判断当前版本
+func (m *Manager) Versions(names []string, releasever, basearch string) ([]*VersionInfo, error) {
+	catalog, err := DefaultCatalog()
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		for _, name := range catalog.Names() {
+			if len(catalog.Repos[name].Versions) > 0 {
+				names = append(names, name)
+			}
+		}
+	}
+	distro, err := m.detector().GetDistroName()
+	if err != nil {
+		return nil, fmt.Errorf("get distro name failed: %v", err)
+	}
+
+	// 已添加的源的地址，按节 ID 索引
+	files, err := m.loadRepoFiles()
+	if err != nil {
+		return nil, err
+	}
+	added := map[string]*RepoInfo{}
+	for _, file := range files {
+		for _, section := range file.File.Sections {
+			added[section.ID] = newRepoInfo(file.Path, section)
+		}
+	}
+
+	var infos []*VersionInfo
+	for _, name := range names {
+		repo, _, err := catalog.resolve(name)
+		if err != nil {
+			return nil, err
+		}
+		if len(repo.Versions) == 0 {
+			return nil, fmt.Errorf("repo %s has no versions", repo.Name)
+		}
+		for _, v := range repo.Versions {
+			versioned, err := repo.WithVersion(v.Version)
+			if err != nil {
+				return nil, err
+			}
+			info := &VersionInfo{Repo: repo.Name, Version: v.Version, Default: v.Version == repo.DefaultVersionName()}
+			if versioned.InstallMethod(distro, releasever, basearch) == InstallReleaseRPM {
+				// release RPM 提供的 .repo 文件由 RPM 管理，按该版本的 release 包是否已安装判断
+				info.Current = m.packageInstalled(versioned.ReleasePackage)
+			} else if current, ok := added[repo.Name]; ok {
+				urls := versioned.URLs(distro, releasever, basearch, false)
+				want := &RepoInfo{BaseURL: urls.BaseURLs, Mirrorlist: urls.Mirrorlist, Metalink: urls.Metalink}
+				info.Current = current.URL() != "" && current.URL() == want.URL()
+			}
+			infos = append(infos, info)
+		}
+	}
+	return infos, nil
+}
+
+// removeAliasFiles 删除以别名命名的旧 .repo 文件（如 mysql57.repo、php7.repo、kubernetes.repo），
+// 避免切换版本后新旧两个版本的源同时存在
+func (m *Manager) removeAliasFiles(tx *Transaction, repo *Repo) error {
+	catalog, err := DefaultCatalog()
+	if err != nil {
+		return err
+	}
+	for _, alias := range catalog.AliasesOf(repo.Name) {
+		name := alias + ".repo"
+		if _, err := os.Stat(filepath.Join(m.RepoDir, name)); err == nil {
+			tx.Remove(name)
+		}
+	}
+	return nil
+}
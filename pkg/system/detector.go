@@ -3,9 +3,12 @@ package system
 import (
 	"fmt"
 	"io/ioutil"
+	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
 	"strconv"
+	"time"
 )
 
 // Distro 发行版信息
@@ -16,13 +19,20 @@ type Distro struct {
 }
 
 // Detector 系统检测器
-type Detector struct {}
+type Detector struct {
+	Root string // 备用根目录（如挂载的镜像或 chroot），为空时检测当前系统
+}
 
 // NewDetector 创建系统检测器实例
 func NewDetector() *Detector {
 	return &Detector{}
 }
 
+// path 返回备用根目录下的路径
+func (d *Detector) path(name string) string {
+	return filepath.Join(d.Root, name)
+}
+
 // Detect 检测系统信息
 func (d *Detector) Detect() (*Distro, error) {
 	distro, err := d.detectDistro()
@@ -54,7 +64,7 @@ func (d *Detector) detectDistro() (*Distro, error) {
 	}
 
 	// 如果从 /etc/centos-release 获取失败，尝试从 /etc/os-release 文件获取
-	content, err := ioutil.ReadFile("/etc/os-release")
+	content, err := ioutil.ReadFile(d.path("/etc/os-release"))
 	if err != nil {
 		return nil, fmt.Errorf("read os-release failed: %v", err)
 	}
@@ -85,7 +95,7 @@ func (d *Detector) detectDistro() (*Distro, error) {
 // detectVersionFromOtherFiles 从其他文件检测版本号
 func (d *Detector) detectVersionFromOtherFiles(distro *Distro) error {
 	// 尝试从 /etc/centos-release 文件获取，支持 CentOS 7.9.2009 这样的格式
-	if content, err := ioutil.ReadFile("/etc/centos-release"); err == nil {
+	if content, err := ioutil.ReadFile(d.path("/etc/centos-release")); err == nil {
 		// 匹配格式如 "CentOS Linux release 7.9.2009 (Core)" 或 "CentOS Stream release 8" 的版本号
 		if match := regexp.MustCompile(`CentOS.*release\s+([0-9.]+(?:\s+\(Core\))?)`).FindStringSubmatch(string(content)); len(match) > 1 {
 			// 提取版本号，去除括号部分
@@ -97,9 +107,11 @@ func (d *Detector) detectVersionFromOtherFiles(distro *Distro) error {
 	}
 
 	// 尝试从 /etc/redhat-release 文件获取
-	if content, err := ioutil.ReadFile("/etc/redhat-release"); err == nil {
-		if match := regexp.MustCompile(`.*release\s+([0-9.]+)`).FindStringSubmatch(string(content)); len(match) > 1 {
-			distro.Version = match[1]
+	if content, err := ioutil.ReadFile(d.path("/etc/redhat-release")); err == nil {
+		// 匹配格式如 "Rocky Linux release 9.3 (Blue Onyx)"，release 之前是发行版名称
+		if match := regexp.MustCompile(`(.*?)\s*release\s+([0-9.]+)`).FindStringSubmatch(string(content)); len(match) > 2 {
+			distro.Name = strings.TrimSpace(match[1])
+			distro.Version = match[2]
 			return nil
 		}
 	}
@@ -110,8 +122,14 @@ func (d *Detector) detectVersionFromOtherFiles(distro *Distro) error {
 // detectArch 检测系统架构
 func (d *Detector) detectArch() (string, error) {
 	// 读取 /proc/version 文件
-	content, err := ioutil.ReadFile("/proc/version")
+	content, err := ioutil.ReadFile(d.path("/proc/version"))
 	if err != nil {
+		// 挂载的镜像中通常没有 /proc，使用当前系统的架构
+		if d.Root != "" {
+			if arch, ok := goArchs[runtime.GOARCH]; ok {
+				return arch, nil
+			}
+		}
 		return "", fmt.Errorf("read proc/version failed: %v", err)
 	}
 
@@ -129,6 +147,14 @@ func (d *Detector) detectArch() (string, error) {
 	return "", fmt.Errorf("detect arch failed")
 }
 
+// goArchs Go 架构名称与 rpm 架构名称的对应关系
+var goArchs = map[string]string{
+	"amd64": "x86_64",
+	"386":   "i686",
+	"arm64": "aarch64",
+	"arm":   "armv7l",
+}
+
 // normalizeDistroName 规范化发行版名称
 func (d *Detector) normalizeDistroName(name string) string {
 	name = strings.ToLower(name)
@@ -238,6 +264,41 @@ func (d *Detector) IsVersionExpired() (bool, error) {
 	return false, nil
 }
 
+// eolDates 已停止维护、软件包已移到 vault 的发行版版本
+var eolDates = map[string]string{
+	"centos7":        "2024-06-30",
+	"centos8":        "2021-12-31",
+	"centos-stream8": "2024-05-31",
+}
+
+// eolKey 返回 eolDates 中的键，CentOS Linux 的版本号带小版本，Stream 只有主版本号
+func (d *Distro) eolKey() string {
+	parts := strings.Split(d.Version, ".")
+	if d.Name == "centos" && parts[0] != "7" && len(parts) == 1 {
+		return "centos-stream" + parts[0]
+	}
+	return d.Name + parts[0]
+}
+
+// EOLDate 获取停止维护的日期，未收录的版本返回 false
+func (d *Distro) EOLDate() (time.Time, bool) {
+	date, ok := eolDates[d.eolKey()]
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// IsEOL 检查在指定时间是否已停止维护
+func (d *Distro) IsEOL(now time.Time) bool {
+	date, ok := d.EOLDate()
+	return ok && now.After(date)
+}
+
 // GetDistroName 获取规范化的发行版名称
 func (d *Detector) GetDistroName() (string, error) {
 	distro, err := d.Detect()
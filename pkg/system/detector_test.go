@@ -0,0 +1,161 @@
+package system
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+const procVersionX86 = "Linux version 5.14.0-362.8.1.el9_3.x86_64 (mockbuild@iad1-prod-build001.bld.equ.rockylinux.org) " +
+	"(gcc (GCC) 11.4.1 20230605 (Red Hat 11.4.1-2), GNU ld version 2.35.2-42.el9) #1 SMP PREEMPT_DYNAMIC Tue Nov 7 14:54:22 UTC 2023\n"
+
+// writeRoot 在临时目录中写入备用根目录下的文件
+func writeRoot(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  Distro
+	}{
+		{
+			name: "Rocky Linux 9 os-release",
+			files: map[string]string{
+				"etc/os-release": "NAME=\"Rocky Linux\"\nVERSION=\"9.3 (Blue Onyx)\"\nID=\"rocky\"\nID_LIKE=\"rhel centos fedora\"\nVERSION_ID=\"9.3\"\n",
+				"proc/version":   procVersionX86,
+			},
+			want: Distro{Name: "rockylinux", Version: "9.3", Arch: "x86_64"},
+		},
+		{
+			name: "Rocky Linux 9 redhat-release",
+			files: map[string]string{
+				"etc/os-release":     "NAME=\"Rocky Linux\"\nVERSION_ID=\"9.3\"\n",
+				"etc/redhat-release": "Rocky Linux release 9.3 (Blue Onyx)\n",
+				"proc/version":       procVersionX86,
+			},
+			want: Distro{Name: "rockylinux", Version: "9.3", Arch: "x86_64"},
+		},
+		{
+			name: "RHEL 8 redhat-release",
+			files: map[string]string{
+				"etc/redhat-release": "Red Hat Enterprise Linux release 8.9 (Ootpa)\n",
+				"proc/version":       "Linux version 4.18.0-513.5.1.el8_9.aarch64 (mockbuild@arm64-026.build.eng.bos.redhat.com)\n",
+			},
+			want: Distro{Name: "rhel", Version: "8.9", Arch: "aarch64"},
+		},
+		{
+			name: "CentOS 7 centos-release",
+			files: map[string]string{
+				"etc/os-release":     "NAME=\"CentOS Linux\"\nVERSION=\"7 (Core)\"\nVERSION_ID=\"7\"\n",
+				"etc/centos-release": "CentOS Linux release 7.9.2009 (Core)\n",
+				"etc/redhat-release": "CentOS Linux release 7.9.2009 (Core)\n",
+				"proc/version":       "Linux version 3.10.0-1160.el7.x86_64 (mockbuild@kbuilder.bsys.centos.org)\n",
+			},
+			want: Distro{Name: "centos", Version: "7.9.2009", Arch: "x86_64"},
+		},
+		{
+			name: "CentOS Stream 8 centos-release",
+			files: map[string]string{
+				"etc/os-release":     "NAME=\"CentOS Stream\"\nVERSION=\"8\"\nVERSION_ID=\"8\"\n",
+				"etc/centos-release": "CentOS Stream release 8\n",
+				"proc/version":       "Linux version 4.18.0-553.el8.x86_64 (mockbuild@x86-05.stream.rdu2.redhat.com)\n",
+			},
+			want: Distro{Name: "centos", Version: "8", Arch: "x86_64"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &Detector{Root: writeRoot(t, tt.files)}
+			got, err := d.Detect()
+			if err != nil {
+				t.Fatalf("Detect() error = %v", err)
+			}
+			if *got != tt.want {
+				t.Errorf("Detect() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"没有 os-release", map[string]string{"proc/version": procVersionX86}, "read os-release failed"},
+		{"os-release 中没有版本号", map[string]string{"etc/os-release": "NAME=\"Fedora Linux\"\n"}, "detect version failed"},
+		{"未知架构", map[string]string{"etc/os-release": "NAME=Fedora\nVERSION_ID=40\n", "proc/version": "Linux version 6.8.5-301.fc40.s390x\n"}, "detect arch failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &Detector{Root: writeRoot(t, tt.files)}
+			_, err := d.Detect()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Detect() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectArchFallback(t *testing.T) {
+	want, ok := goArchs[runtime.GOARCH]
+	if !ok {
+		t.Skipf("no rpm arch for GOARCH %s", runtime.GOARCH)
+	}
+	// 挂载的镜像中没有 /proc，使用当前系统的架构
+	d := &Detector{Root: writeRoot(t, map[string]string{"etc/os-release": "NAME=\"AlmaLinux\"\nVERSION_ID=\"8.9\"\n"})}
+	basearch, err := d.GetBasearch()
+	if err != nil {
+		t.Fatalf("GetBasearch() error = %v", err)
+	}
+	if basearch != want {
+		t.Errorf("GetBasearch() = %s, want %s", basearch, want)
+	}
+	major, err := d.GetMajorReleasever()
+	if err != nil || major != "8" {
+		t.Errorf("GetMajorReleasever() = %s, %v, want 8", major, err)
+	}
+}
+
+func TestIsEOL(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		distro Distro
+		key    string
+		eol    bool
+	}{
+		{Distro{Name: "centos", Version: "7.9.2009"}, "centos7", true},
+		{Distro{Name: "centos", Version: "8.5.2111"}, "centos8", true},
+		{Distro{Name: "centos", Version: "8"}, "centos-stream8", true},
+		{Distro{Name: "centos", Version: "9"}, "centos-stream9", false},
+		{Distro{Name: "rockylinux", Version: "9.3"}, "rockylinux9", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := tt.distro.eolKey(); got != tt.key {
+				t.Errorf("eolKey() = %s, want %s", got, tt.key)
+			}
+			if got := tt.distro.IsEOL(now); got != tt.eol {
+				t.Errorf("IsEOL() = %v, want %v", got, tt.eol)
+			}
+		})
+	}
+}